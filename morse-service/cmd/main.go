@@ -1,17 +1,17 @@
 package main
 
 import (
-	"log"
+	"context"
 	"os"
 
-	"sprint6/internal/server"
+	"sprint6/internal/cli"
 )
 
 func main() {
-	logger := log.New(os.Stdout, "http ", log.LstdFlags|log.Lshortfile)
-	srv := server.New(logger)
-
-	if err := srv.HTTP.ListenAndServe(); err != nil {
-		logger.Fatal(err)
-	}
+	os.Exit(cli.Serve(context.Background(), os.Args[1:], cli.Env{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Getenv: os.Getenv,
+	}))
 }
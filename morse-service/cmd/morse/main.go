@@ -0,0 +1,19 @@
+// Command morse converts between text and Morse code, keys WAV files and
+// runs the HTTP service. Run it without arguments for the commands.
+package main
+
+import (
+	"context"
+	"os"
+
+	"sprint6/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(context.Background(), os.Args[1:], cli.Env{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Getenv: os.Getenv,
+	}))
+}
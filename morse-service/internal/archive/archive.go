@@ -0,0 +1,261 @@
+// Package archive reads and writes the ZIP and tar.gz archives of batch
+// conversions. Reading is bounded by Limits, so that a small upload cannot
+// unpack into an unbounded number of files or bytes.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	Zip   Format = "zip"
+	TarGz Format = "tar.gz"
+)
+
+// ContentType is the media type of an archive in the format.
+func (f Format) ContentType() string {
+	if f == Zip {
+		return "application/zip"
+	}
+
+	return "application/gzip"
+}
+
+// Ext is the file name extension of the format, with the leading dot.
+func (f Format) Ext() string {
+	return "." + string(f)
+}
+
+var (
+	ErrUnsupported    = errors.New("unsupported archive: want ZIP or tar.gz")
+	ErrTooManyEntries = errors.New("archive has too many entries")
+	ErrTooLarge       = errors.New("archive is too large when decompressed")
+)
+
+// Limits bound what a Reader unpacks. MaxEntries counts every entry,
+// directories included; MaxSize is the total size of the file contents.
+type Limits struct {
+	MaxEntries int
+	MaxSize    int64
+}
+
+// File is a regular file of an archive. Its contents must be read before
+// the next call to Next.
+type File struct {
+	Name string
+	io.Reader
+}
+
+// Reader iterates over the regular files of an archive; directories, links
+// and other entries are skipped but count towards MaxEntries.
+type Reader struct {
+	format    Format
+	limits    Limits
+	entries   int
+	remaining int64
+
+	zip  []*zip.File
+	tar  *tar.Reader
+	open io.Closer
+}
+
+// NewReader detects the format of the size bytes in r and opens it.
+func NewReader(r io.ReaderAt, size int64, limits Limits) (*Reader, error) {
+	magic := make([]byte, 4)
+	n, _ := r.ReadAt(magic, 0)
+	magic = magic[:n]
+
+	ar := &Reader{limits: limits, remaining: limits.MaxSize}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		if len(zr.File) > limits.MaxEntries {
+			return nil, fmt.Errorf("%w: %d, at most %d", ErrTooManyEntries, len(zr.File), limits.MaxEntries)
+		}
+		ar.format, ar.zip = Zip, zr.File
+	case bytes.HasPrefix(magic, []byte("\x1f\x8b")):
+		gz, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		ar.format, ar.tar = TarGz, tar.NewReader(gz)
+	default:
+		return nil, ErrUnsupported
+	}
+
+	return ar, nil
+}
+
+func (r *Reader) Format() Format {
+	return r.format
+}
+
+// Next returns the next regular file, or io.EOF after the last one.
+func (r *Reader) Next() (*File, error) {
+	if r.open != nil {
+		_ = r.open.Close()
+		r.open = nil
+	}
+
+	for {
+		if r.entries == r.limits.MaxEntries && r.tar != nil {
+			// Anything but the end of the archive is one entry too many.
+			if _, err := r.tar.Next(); err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("%w: at most %d", ErrTooManyEntries, r.limits.MaxEntries)
+		}
+
+		name, size, body, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		r.entries++
+		if body == nil {
+			continue
+		}
+
+		if size > r.remaining {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, r.limits.MaxSize)
+		}
+
+		return &File{Name: name, Reader: &budgetReader{r: body, remaining: &r.remaining, max: r.limits.MaxSize}}, nil
+	}
+}
+
+// next returns the next entry with its declared size; body is nil for
+// anything but a regular file.
+func (r *Reader) next() (name string, size int64, body io.Reader, err error) {
+	if r.tar != nil {
+		hdr, err := r.tar.Next()
+		if err != nil {
+			return "", 0, nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return hdr.Name, 0, nil, nil
+		}
+		return hdr.Name, hdr.Size, r.tar, nil
+	}
+
+	if r.entries == len(r.zip) {
+		return "", 0, nil, io.EOF
+	}
+	f := r.zip[r.entries]
+	if !f.Mode().IsRegular() {
+		return f.Name, 0, nil, nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	r.open = rc
+
+	return f.Name, int64(min(f.UncompressedSize64, 1<<63-1)), rc, nil
+}
+
+// budgetReader fails with ErrTooLarge once the archive has produced more
+// than MaxSize bytes, whatever its headers claim.
+type budgetReader struct {
+	r         io.Reader
+	remaining *int64
+	max       int64
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > *b.remaining+1 {
+		p = p[:*b.remaining+1]
+	}
+
+	n, err := b.r.Read(p)
+	if int64(n) > *b.remaining {
+		*b.remaining = 0
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, b.max)
+	}
+	*b.remaining -= int64(n)
+
+	return n, err
+}
+
+// CleanName returns name as a relative slash-separated path, and false if
+// it would point outside the directory the archive is extracted to.
+func CleanName(name string) (string, bool) {
+	name = path.Clean(strings.ReplaceAll(name, `\`, "/"))
+
+	switch {
+	case name == "." || name == ".." || strings.HasPrefix(name, "../"):
+		return "", false
+	case path.IsAbs(name) || len(name) > 1 && name[1] == ':':
+		return "", false
+	}
+
+	return name, true
+}
+
+// Writer writes an archive of regular files.
+type Writer struct {
+	zip     *zip.Writer
+	gz      *gzip.Writer
+	tar     *tar.Writer
+	modTime time.Time
+}
+
+func NewWriter(w io.Writer, format Format) *Writer {
+	aw := &Writer{modTime: time.Now()}
+	if format == Zip {
+		aw.zip = zip.NewWriter(w)
+	} else {
+		aw.gz = gzip.NewWriter(w)
+		aw.tar = tar.NewWriter(aw.gz)
+	}
+
+	return aw
+}
+
+func (w *Writer) WriteFile(name string, data []byte) error {
+	if w.zip != nil {
+		fw, err := w.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: w.modTime})
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(data)
+		return err
+	}
+
+	err := w.tar.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(data)),
+		Mode:     0o644,
+		ModTime:  w.modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.tar.Write(data)
+
+	return err
+}
+
+func (w *Writer) Close() error {
+	if w.zip != nil {
+		return w.zip.Close()
+	}
+
+	return errors.Join(w.tar.Close(), w.gz.Close())
+}
@@ -0,0 +1,158 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var limits = Limits{MaxEntries: 10, MaxSize: 1 << 10}
+
+func readAll(t *testing.T, data []byte, limits Limits) (Format, map[string]string, error) {
+	t.Helper()
+
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)), limits)
+	if err != nil {
+		return "", nil, err
+	}
+
+	files := map[string]string{}
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			return r.Format(), files, nil
+		}
+		if err != nil {
+			return r.Format(), files, err
+		}
+
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return r.Format(), files, err
+		}
+		files[f.Name] = string(b)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{Zip, TarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, format)
+			require.NoError(t, w.WriteFile("a.txt", []byte("СОС")))
+			require.NoError(t, w.WriteFile("dir/b.txt", []byte("... --- ...")))
+			require.NoError(t, w.WriteFile("empty.txt", nil))
+			require.NoError(t, w.Close())
+
+			got, files, err := readAll(t, buf.Bytes(), limits)
+			require.NoError(t, err)
+			assert.Equal(t, format, got)
+			assert.Equal(t, map[string]string{"a.txt": "СОС", "dir/b.txt": "... --- ...", "empty.txt": ""}, files)
+		})
+	}
+}
+
+func TestSkipsDirectoriesAndLinks(t *testing.T) {
+	var zbuf bytes.Buffer
+	zw := zip.NewWriter(&zbuf)
+	_, err := zw.Create("dir/")
+	require.NoError(t, err)
+	fw, err := zw.Create("dir/a.txt")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	_, files, err := readAll(t, zbuf.Bytes(), limits)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"dir/a.txt": "a"}, files)
+
+	var tbuf bytes.Buffer
+	gz := gzip.NewWriter(&tbuf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0o755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "dir/passwd", Linkname: "/etc/passwd"}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "dir/a.txt", Size: 1, Mode: 0o644}))
+	_, err = tw.Write([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	_, files, err = readAll(t, tbuf.Bytes(), limits)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"dir/a.txt": "a"}, files)
+}
+
+func TestLimits(t *testing.T) {
+	archive := func(format Format, files int, size int) []byte {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, format)
+		for i := range files {
+			require.NoError(t, w.WriteFile(strings.Repeat("f", i+1), bytes.Repeat([]byte{'0'}, size)))
+		}
+		require.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+
+	for _, format := range []Format{Zip, TarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			_, _, err := readAll(t, archive(format, 10, 1), limits)
+			require.NoError(t, err)
+
+			_, _, err = readAll(t, archive(format, 11, 1), limits)
+			assert.ErrorIs(t, err, ErrTooManyEntries)
+
+			_, _, err = readAll(t, archive(format, 2, 512), limits)
+			require.NoError(t, err)
+
+			_, _, err = readAll(t, archive(format, 3, 512), limits)
+			assert.ErrorIs(t, err, ErrTooLarge)
+
+			// A bomb: a megabyte of zeros compresses to about a kilobyte.
+			data := archive(format, 1, 1<<20)
+			assert.Less(t, len(data), 4<<10)
+			_, _, err = readAll(t, data, limits)
+			assert.ErrorIs(t, err, ErrTooLarge)
+		})
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	for _, data := range []string{"", "plain text", "PK"} {
+		_, _, err := readAll(t, []byte(data), limits)
+		assert.ErrorIs(t, err, ErrUnsupported, data)
+	}
+
+	_, _, err := readAll(t, []byte("PK\x03\x04 broken"), limits)
+	assert.ErrorIs(t, err, zip.ErrFormat)
+}
+
+func TestCleanName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"a.txt", "a.txt", true},
+		{"dir/./b.txt", "dir/b.txt", true},
+		{`dir\c.txt`, "dir/c.txt", true},
+		{"dir/../d.txt", "d.txt", true},
+		{"../e.txt", "", false},
+		{"dir/../../f.txt", "", false},
+		{"/etc/passwd", "", false},
+		{`C:\windows\g.txt`, "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := CleanName(tt.name)
+		assert.Equal(t, tt.ok, ok, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
@@ -0,0 +1,379 @@
+// Package charset detects the encoding of uploaded text and transcodes it to
+// UTF-8. Besides UTF-8 it knows the encodings Russian text files come in:
+// UTF-16, Windows-1251 and KOI8-R.
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	UTF8        = "utf-8"
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+	Windows1251 = "windows-1251"
+	KOI8R       = "koi8-r"
+)
+
+// Names are the charsets NewReader accepts, besides their aliases.
+var Names = []string{UTF8, UTF16LE, UTF16BE, Windows1251, KOI8R}
+
+var (
+	ErrUnknown = errors.New("unknown charset")
+	ErrNotText = errors.New("not a text file")
+)
+
+var aliases = map[string]string{
+	"utf8":        UTF8,
+	"utf-16":      UTF16LE,
+	"utf16":       UTF16LE,
+	"utf16le":     UTF16LE,
+	"utf16be":     UTF16BE,
+	"cp1251":      Windows1251,
+	"windows1251": Windows1251,
+	"win-1251":    Windows1251,
+	"koi8r":       KOI8R,
+	"koi8":        KOI8R,
+}
+
+// sniffSize is how much of the input Detect looks at.
+const sniffSize = 4 << 10
+
+var boms = []struct {
+	bom     string
+	charset string
+}{
+	{"\xef\xbb\xbf", UTF8},
+	{"\xff\xfe", UTF16LE},
+	{"\xfe\xff", UTF16BE},
+}
+
+// Lookup returns the canonical name of a charset; it returns "" for "" and
+// "auto", which leave the charset to Detect.
+func Lookup(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case name == "" || name == "auto":
+		return "", nil
+	case aliases[name] != "":
+		return aliases[name], nil
+	}
+
+	for _, n := range Names {
+		if n == name {
+			return n, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w %q, want one of %s", ErrUnknown, name, strings.Join(Names, ", "))
+}
+
+// Detect guesses the charset of text starting with sample: by its byte
+// order mark, then by the zero bytes of UTF-16, then by whether it is valid
+// UTF-8. Anything else is taken for Cyrillic in whichever of Windows-1251
+// and KOI8-R makes the more frequent Russian letters.
+func Detect(sample []byte) string {
+	for _, b := range boms {
+		if bytes.HasPrefix(sample, []byte(b.bom)) {
+			return b.charset
+		}
+	}
+
+	if cs := detectUTF16(sample); cs != "" {
+		return cs
+	}
+
+	if validUTF8(sample) {
+		return UTF8
+	}
+
+	if letterScore(sample, &koi8r) > letterScore(sample, &windows1251) {
+		return KOI8R
+	}
+
+	return Windows1251
+}
+
+// detectUTF16 recognizes UTF-16 text without a byte order mark by its high
+// bytes: 0x00 for Latin and 0x04 for Cyrillic, which text in the other
+// charsets does not have.
+func detectUTF16(sample []byte) string {
+	pairs := len(sample) / 2
+	if pairs == 0 {
+		return ""
+	}
+
+	var even, odd int
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i] == 0 || sample[i] == 4 {
+			even++
+		}
+		if sample[i+1] == 0 || sample[i+1] == 4 {
+			odd++
+		}
+	}
+
+	switch {
+	case odd*10 >= pairs*9 && even*10 < pairs:
+		return UTF16LE
+	case even*10 >= pairs*9 && odd*10 < pairs:
+		return UTF16BE
+	default:
+		return ""
+	}
+}
+
+// validUTF8 reports whether sample is UTF-8, but for a rune cut off at its end.
+func validUTF8(sample []byte) bool {
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size == 1 {
+			return len(sample)-i < utf8.UTFMax && !utf8.FullRune(sample[i:])
+		}
+		i += size
+	}
+
+	return true
+}
+
+// letterFrequency is the share of the Russian letters in text, in percent.
+var letterFrequency = map[rune]float64{
+	'о': 10.97, 'е': 8.45, 'а': 8.01, 'и': 7.35, 'н': 6.70, 'т': 6.26, 'с': 5.47,
+	'р': 4.73, 'в': 4.54, 'л': 4.40, 'к': 3.49, 'м': 3.21, 'д': 2.98, 'п': 2.81,
+	'у': 2.62, 'я': 2.01, 'ы': 1.90, 'ь': 1.74, 'г': 1.70, 'з': 1.65, 'б': 1.59,
+	'ч': 1.44, 'й': 1.21, 'х': 0.97, 'ж': 0.94, 'ш': 0.73, 'ю': 0.64, 'ц': 0.48,
+	'щ': 0.36, 'э': 0.32, 'ф': 0.26, 'ъ': 0.04, 'ё': 0.04,
+}
+
+func letterScore(sample []byte, table *[128]rune) float64 {
+	var score float64
+	for _, b := range sample {
+		if b >= 0x80 {
+			score += letterFrequency[unicode.ToLower(table[b-0x80])]
+		}
+	}
+
+	return score
+}
+
+// NewReader returns a reader of r transcoded to UTF-8 from the named
+// charset. An empty name detects the charset from the start of r. A byte
+// order mark is dropped, and it overrides the name.
+func NewReader(r io.Reader, name string) (*Reader, error) {
+	name, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReaderSize(r, sniffSize)
+	head, err := br.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+
+	detected := name == ""
+	for _, b := range boms {
+		if bytes.HasPrefix(head, []byte(b.bom)) {
+			name, detected = b.charset, false
+			_, _ = br.Discard(len(b.bom))
+			break
+		}
+	}
+	if name == "" {
+		name = Detect(head)
+	}
+
+	t := &Reader{r: br, name: name}
+	switch name {
+	case UTF16LE:
+		t.decode = decodeUTF16(binary.LittleEndian)
+	case UTF16BE:
+		t.decode = decodeUTF16(binary.BigEndian)
+	case Windows1251:
+		t.decode = decodeTable(&windows1251)
+	case KOI8R:
+		t.decode = decodeTable(&koi8r)
+	default:
+		t.decode = t.decodeUTF8
+		t.fallback = detected
+	}
+
+	return t, nil
+}
+
+// A decoder appends the UTF-8 of src to dst and returns how much of src it
+// consumed; it leaves an incomplete character for the next call unless eof.
+type decoder func(dst, src []byte, eof bool) ([]byte, int, error)
+
+// Reader transcodes text to UTF-8. It fails with ErrNotText on a zero byte
+// or, in UTF-8, on an invalid sequence, which the start of the text that
+// Detect looks at need not show.
+type Reader struct {
+	r      io.Reader
+	name   string
+	decode decoder
+	buf    [4 << 10]byte
+	in     []byte
+	out    []byte
+	err    error
+
+	// fallback is whether detected UTF-8 may still turn out to be a
+	// single-byte charset: it is until the first non-ASCII character.
+	fallback bool
+	// offset is how much of the input has been decoded.
+	offset int64
+}
+
+// Charset returns the canonical name of the charset read. Text detected as
+// UTF-8 changes to Windows-1251 or KOI8-R if it is not UTF-8 after an ASCII
+// start.
+func (r *Reader) Charset() string {
+	return r.name
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := r.r.Read(r.buf[:])
+		r.in = append(r.in, r.buf[:n]...)
+		r.err = err
+
+		var consumed int
+		r.out, consumed, err = r.decode(r.out[:0], r.in, r.err != nil)
+		if err != nil {
+			r.err = err
+		}
+		if i := bytes.IndexByte(r.out, 0); i >= 0 {
+			r.out = r.out[:i]
+			r.err = fmt.Errorf("%w: zero byte", ErrNotText)
+		}
+		r.offset += int64(consumed)
+		r.in = append(r.in[:0], r.in[consumed:]...)
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+// decodeUTF8 validates UTF-8. On the first invalid sequence it goes on in
+// the single-byte charset the rest of src looks like, if it may fall back,
+// and fails otherwise.
+func (r *Reader) decodeUTF8(dst, src []byte, eof bool) ([]byte, int, error) {
+	i := 0
+	for i < len(src) {
+		if src[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if !eof && !utf8.FullRune(src[i:]) {
+			break
+		}
+
+		c, size := utf8.DecodeRune(src[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, src[:i]...)
+			if !r.fallback {
+				return dst, i, fmt.Errorf("%w: invalid UTF-8 at byte %d", ErrNotText, r.offset+int64(i))
+			}
+
+			r.name = Windows1251
+			table := &windows1251
+			if letterScore(src[i:], &koi8r) > letterScore(src[i:], &windows1251) {
+				r.name, table = KOI8R, &koi8r
+			}
+			r.decode = decodeTable(table)
+
+			dst, n, err := r.decode(dst, src[i:], eof)
+			return dst, i + n, err
+		}
+
+		r.fallback = false
+		i += size
+	}
+
+	return append(dst, src[:i]...), i, nil
+}
+
+func decodeTable(table *[128]rune) decoder {
+	return func(dst, src []byte, _ bool) ([]byte, int, error) {
+		for _, b := range src {
+			if b < 0x80 {
+				dst = append(dst, b)
+			} else {
+				dst = utf8.AppendRune(dst, table[b-0x80])
+			}
+		}
+
+		return dst, len(src), nil
+	}
+}
+
+func decodeUTF16(order binary.ByteOrder) decoder {
+	return func(dst, src []byte, eof bool) ([]byte, int, error) {
+		i := 0
+		for ; i+1 < len(src); i += 2 {
+			r := rune(order.Uint16(src[i:]))
+			if utf16.IsSurrogate(r) {
+				if i+3 >= len(src) {
+					if !eof {
+						break
+					}
+					dst = utf8.AppendRune(dst, utf8.RuneError)
+					continue
+				}
+				if r = utf16.DecodeRune(r, rune(order.Uint16(src[i+2:]))); r != utf8.RuneError {
+					i += 2
+				}
+			}
+			dst = utf8.AppendRune(dst, r)
+		}
+
+		if eof && i < len(src) {
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+			i = len(src)
+		}
+
+		return dst, i, nil
+	}
+}
+
+// The upper halves of the single-byte charsets, from 0x80.
+var (
+	windows1251 = table("" +
+		"ЂЃ‚ѓ„…†‡€‰Љ‹ЊЌЋЏ" +
+		"ђ‘’“”•–—\ufffd™љ›њќћџ" +
+		"\u00a0ЎўЈ¤Ґ¦§Ё©Є«¬\u00ad®Ї" +
+		"°±Ііґµ¶·ё№є»јЅѕї" +
+		"АБВГДЕЖЗИЙКЛМНОП" +
+		"РСТУФХЦЧШЩЪЫЬЭЮЯ" +
+		"абвгдежзийклмноп" +
+		"рстуфхцчшщъыьэюя")
+
+	koi8r = table("" +
+		"─│┌┐└┘├┤┬┴┼▀▄█▌▐" +
+		"░▒▓⌠■∙√≈≤≥\u00a0⌡°²·÷" +
+		"═║╒ё╓╔╕╖╗╘╙╚╛╜╝╞" +
+		"╟╠╡Ё╢╣╤╥╦╧╨╩╪╫╬©" +
+		"юабцдефгхийклмно" +
+		"пярстужвьызшэщчъ" +
+		"ЮАБЦДЕФГХИЙКЛМНО" +
+		"ПЯРСТУЖВЬЫЗШЭЩЧЪ")
+)
+
+func table(s string) [128]rune {
+	return [128]rune([]rune(s))
+}
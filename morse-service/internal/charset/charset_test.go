@@ -0,0 +1,186 @@
+package charset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const text = "Привет, мир! Это проверка связи: СОС."
+
+func encodeTable(t *testing.T, s string, table *[128]rune) []byte {
+	t.Helper()
+
+	var out []byte
+	for _, r := range s {
+		if r < 0x80 {
+			out = append(out, byte(r))
+			continue
+		}
+		i := bytes.IndexRune([]byte(string(table[:])), r)
+		require.GreaterOrEqual(t, i, 0, "%q", r)
+		out = append(out, byte(0x80+len([]rune(string(table[:])[:i]))))
+	}
+
+	return out
+}
+
+func encodeUTF16(s string, order binary.AppendByteOrder) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = order.AppendUint16(out, u)
+	}
+	return out
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"utf-8", []byte(text), UTF8},
+		{"ascii", []byte("... --- ..."), UTF8},
+		{"empty", nil, UTF8},
+		{"utf-8 bom", []byte("\xef\xbb\xbf" + text), UTF8},
+		{"utf-8 cut rune", []byte(text)[:2], UTF8},
+		{"utf-16le bom", append([]byte("\xff\xfe"), encodeUTF16(text, binary.LittleEndian)...), UTF16LE},
+		{"utf-16be bom", append([]byte("\xfe\xff"), encodeUTF16(text, binary.BigEndian)...), UTF16BE},
+		{"utf-16le", encodeUTF16(text, binary.LittleEndian), UTF16LE},
+		{"utf-16be", encodeUTF16(text, binary.BigEndian), UTF16BE},
+		{"utf-16le morse", encodeUTF16("... --- ...", binary.LittleEndian), UTF16LE},
+		{"windows-1251", encodeTable(t, text, &windows1251), Windows1251},
+		{"koi8-r", encodeTable(t, text, &koi8r), KOI8R},
+		{"windows-1251 upper", encodeTable(t, "СОС ПРИВЕТ", &windows1251), Windows1251},
+		{"koi8-r upper", encodeTable(t, "СОС ПРИВЕТ", &koi8r), KOI8R},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Detect(tt.data))
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		charset string
+		want    string
+		wantCS  string
+	}{
+		{"utf-8", []byte(text), "", text, UTF8},
+		{"utf-8 bom", []byte("\xef\xbb\xbf" + text), "", text, UTF8},
+		{"utf-16le bom", append([]byte("\xff\xfe"), encodeUTF16(text, binary.LittleEndian)...), "", text, UTF16LE},
+		{"utf-16be", encodeUTF16(text, binary.BigEndian), "", text, UTF16BE},
+		{"surrogates", encodeUTF16("СОС 📡", binary.LittleEndian), "utf-16", "СОС 📡", UTF16LE},
+		{"windows-1251", encodeTable(t, text, &windows1251), "", text, Windows1251},
+		{"koi8-r", encodeTable(t, text, &koi8r), "", text, KOI8R},
+		{"override", encodeTable(t, "СОС", &windows1251), "KOI8-R", "яня", KOI8R},
+		{"alias", encodeTable(t, "СОС", &windows1251), "cp1251", "СОС", Windows1251},
+		{"bom wins", []byte("\xef\xbb\xbfСОС"), "windows-1251", "СОС", UTF8},
+		{"odd byte", []byte("\xff\xfeA\x00B"), "", "A�", UTF16LE},
+		{"lone surrogate", []byte("\xff\xfe\x00\xd8A\x00"), "", "�A", UTF16LE},
+		{"cut surrogate", []byte("\xff\xfe\x3d\xd8"), "", "�", UTF16LE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One byte at a time, so that characters are split across reads.
+			r, err := NewReader(iotest.OneByteReader(bytes.NewReader(tt.data)), tt.charset)
+			require.NoError(t, err)
+
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantCS, r.Charset())
+		})
+	}
+}
+
+func TestNewReaderFallback(t *testing.T) {
+	// The ASCII start is longer than the sample Detect looks at.
+	head := strings.Repeat("SOS ", sniffSize)
+
+	for _, tt := range []struct {
+		name  string
+		table *[128]rune
+		want  string
+	}{
+		{"windows-1251", &windows1251, Windows1251},
+		{"koi8-r", &koi8r, KOI8R},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]byte(head), encodeTable(t, text, tt.table)...)
+			r, err := NewReader(bytes.NewReader(data), "")
+			require.NoError(t, err)
+			assert.Equal(t, UTF8, r.Charset())
+
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, head+text, string(got))
+			assert.Equal(t, tt.want, r.Charset())
+		})
+	}
+}
+
+func TestNewReaderNotText(t *testing.T) {
+	cyrillic := strings.Repeat("СОС ", sniffSize)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		charset string
+	}{
+		{"invalid after utf-8", append([]byte(cyrillic), 0xd1, 0xce), ""},
+		{"invalid utf-8 named", []byte("SOS \xd1\xce\xd1"), "utf-8"},
+		{"invalid after bom", []byte("\xef\xbb\xbfSOS \xd1\xce\xd1"), ""},
+		{"zero byte", []byte("SOS\x00\x01\x02"), ""},
+		{"zero byte single-byte", []byte("\xd1\xce\xd1\x00"), "windows-1251"},
+		{"zero utf-16", []byte("\xff\xfeA\x00\x00\x00"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.data), tt.charset)
+			require.NoError(t, err)
+
+			_, err = io.ReadAll(r)
+			assert.ErrorIs(t, err, ErrNotText)
+		})
+	}
+}
+
+func TestNewReaderLarge(t *testing.T) {
+	input := strings.Repeat(text+"\n", 1000)
+
+	r, err := NewReader(bytes.NewReader(encodeUTF16(input, binary.LittleEndian)), "")
+	require.NoError(t, err)
+	assert.Equal(t, UTF16LE, r.Charset())
+
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(got))
+}
+
+func TestLookup(t *testing.T) {
+	for name, want := range map[string]string{
+		"": "", "auto": "", "UTF-8": UTF8, "utf8": UTF8, "Windows-1251": Windows1251,
+		"cp1251": Windows1251, "koi8-r": KOI8R, "utf-16": UTF16LE, "UTF-16BE": UTF16BE,
+	} {
+		got, err := Lookup(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+
+	_, err := Lookup("ebcdic")
+	assert.ErrorIs(t, err, ErrUnknown)
+
+	_, err = NewReader(strings.NewReader("СОС"), "ebcdic")
+	assert.ErrorIs(t, err, ErrUnknown)
+}
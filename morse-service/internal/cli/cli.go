@@ -0,0 +1,461 @@
+// Package cli implements the morse command line tool. Its serve command
+// is also the entry point of the service binary.
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"sprint6/internal/charset"
+	"sprint6/internal/config"
+	"sprint6/internal/server"
+	"sprint6/internal/service"
+	"sprint6/pkg/audio"
+	"sprint6/pkg/morse"
+)
+
+// Exit statuses of the morse tool.
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+	// ExitUnknown means the input had symbols the converter could not
+	// convert.
+	ExitUnknown = 3
+)
+
+// Env is what a command reads and writes besides files.
+type Env struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Getenv func(string) string
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string, env Env) int
+}
+
+var commands = []command{
+	{"encode", "convert text to Morse code", convertCommand("encode", service.DirectionEncode)},
+	{"decode", "convert Morse code to text", convertCommand("decode", service.DirectionDecode)},
+	{"auto", "detect the direction and convert", convertCommand("auto", service.DirectionAuto)},
+	{"wav", "key text or Morse code into a WAV file", wav},
+	{"serve", "run the HTTP service", Serve},
+}
+
+// Run runs the morse tool with the arguments after the program name and
+// returns its exit status.
+func Run(ctx context.Context, args []string, env Env) int {
+	if len(args) == 0 {
+		usage(env.Stderr)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(env.Stdout)
+		return ExitOK
+	}
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(ctx, args[1:], env)
+		}
+	}
+
+	fmt.Fprintf(env.Stderr, "morse: unknown command %q\n", args[0])
+	usage(env.Stderr)
+	return ExitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: morse <command> [flags] [file ...]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Files default to the standard input; - names it too. Run a command")
+	fmt.Fprintln(w, "with -h for its flags. The exit status is 3 if the input had symbols")
+	fmt.Fprintln(w, "that could not be converted, 2 for usage errors and 1 for other errors.")
+}
+
+// Serve runs the HTTP service until ctx is done or the process is
+// interrupted.
+func Serve(ctx context.Context, args []string, env Env) int {
+	cfg, err := config.Load(args, env.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "invalid configuration:\n%v\n", err)
+		return ExitUsage
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		fmt.Fprintf(env.Stderr, "invalid configuration:\n%v\n", err)
+		return ExitUsage
+	}
+
+	logger := slog.New(slog.NewTextHandler(env.Stdout, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+	logger.Debug("config", "config", fmt.Sprintf("%+v", cfg))
+
+	srv, err := server.New(logger, cfg)
+	if err != nil {
+		logger.Error("start", "error", err)
+		return ExitError
+	}
+
+	if err := srv.Run(ctx); err != nil {
+		logger.Error("serve", "error", err)
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+// converterFlags are the flags for the options of morse.NewConverter.
+type converterFlags struct {
+	alphabet  string
+	charSep   string
+	wordSep   string
+	notation  string
+	replace   string
+	trailing  bool
+	lowercase bool
+	prosigns  bool
+	correct   bool
+	decodings []morse.ConverterOption
+}
+
+func (f *converterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.alphabet, "alphabet", morse.DefaultAlphabet, "alphabet: "+strings.Join(morse.Alphabets(), ", "))
+	fs.StringVar(&f.charSep, "char-sep", "", "separator between characters (default the alphabet's)")
+	fs.StringVar(&f.wordSep, "word-sep", "", "separator between words (default the alphabet's)")
+	fs.StringVar(&f.notation, "notation", "", "how dots and dashes are written: "+notationNames())
+	fs.StringVar(&f.replace, "replace", "", "text written in place of unknown symbols")
+	fs.BoolVar(&f.trailing, "trailing", false, "end decoded text with a separator")
+	fs.BoolVar(&f.lowercase, "lowercase", true, "encode lowercase letters as uppercase")
+	fs.BoolVar(&f.prosigns, "prosigns", true, "encode and decode prosigns like <SK>")
+	fs.BoolVar(&f.correct, "correct", false, "correct unknown codes when decoding")
+	fs.Func("decoding", "decode a code shared by several letters as one of them, as `code=letter`; repeatable",
+		func(v string) error {
+			code, letter, ok := strings.Cut(v, "=")
+			r, size := utf8.DecodeRuneInString(letter)
+			if !ok || code == "" || r == utf8.RuneError || size != len(letter) {
+				return fmt.Errorf("want code=letter, got %q", v)
+			}
+			f.decodings = append(f.decodings, morse.WithDecoding(code, r))
+			return nil
+		})
+}
+
+// converter builds the converter; separators keep the alphabet's
+// defaults unless set.
+func (f *converterFlags) converter(fs *flag.FlagSet) (morse.Converter, error) {
+	options := []morse.ConverterOption{
+		morse.WithLowercaseHandling(f.lowercase),
+		morse.WithTrailingSeparator(f.trailing),
+		morse.WithHandler(func(error) string { return f.replace }),
+	}
+	if f.prosigns {
+		options = append(options, morse.WithProsigns(morse.Prosigns))
+	}
+	if f.correct {
+		options = append(options, morse.WithCorrection(1, true))
+	}
+	options = append(options, f.decodings...)
+
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "char-sep":
+			options = append(options, morse.WithCharSeparator(f.charSep))
+		case "word-sep":
+			options = append(options, morse.WithWordSeparator(f.wordSep))
+		}
+	})
+
+	if f.notation != "" {
+		n, ok := morse.LookupNotation(f.notation)
+		if !ok {
+			return morse.Converter{}, fmt.Errorf("unknown notation %q", f.notation)
+		}
+		options = append(options, morse.WithNotation(n))
+	}
+
+	return morse.NewConverterFor(f.alphabet, options...)
+}
+
+func notationNames() string {
+	var names []string
+	for _, n := range morse.Notations() {
+		names = append(names, n.Name())
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// parse parses the flags of a command. It returns the exit status to stop
+// with, if any.
+func parse(fs *flag.FlagSet, args []string, env Env) (int, bool) {
+	fs.SetOutput(env.Stderr)
+
+	err := fs.Parse(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return ExitOK, true
+	case err != nil:
+		return ExitUsage, true
+	default:
+		return 0, false
+	}
+}
+
+func convertCommand(name string, direction service.Direction) func(context.Context, []string, Env) int {
+	return func(_ context.Context, args []string, env Env) int {
+		fs := flag.NewFlagSet("morse "+name, flag.ContinueOnError)
+		var cf converterFlags
+		cf.register(fs)
+		output := fs.String("o", "", "write to this file instead of the standard output")
+		strict := fs.Bool("strict", false, "write nothing if the input has unknown symbols")
+		cs := charsetFlag(fs)
+		if status, stop := parse(fs, args, env); stop {
+			return status
+		}
+
+		c, err := cf.converter(fs)
+		if err == nil {
+			_, err = charset.Lookup(*cs)
+		}
+		if err != nil {
+			fmt.Fprintf(env.Stderr, "morse %s: %v\n", name, err)
+			return ExitUsage
+		}
+
+		return withOutput(*output, env, func(w io.Writer) int {
+			status := ExitOK
+			for _, file := range inputs(fs.Args()) {
+				s := convertFile(w, file, *cs, direction, c, *strict, env)
+				if s == ExitError {
+					return s
+				}
+				status = max(status, s)
+			}
+			return status
+		})
+	}
+}
+
+// convertFile converts one input and writes the result and a newline.
+// Unknown symbols are listed on the standard error.
+func convertFile(w io.Writer, file, cs string, direction service.Direction, c morse.Converter, strict bool, env Env) int {
+	in, name, err := open(file, cs, env)
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "morse: %v\n", err)
+		return ExitError
+	}
+	defer in.Close()
+
+	// In strict mode the result is held back until it is known to be
+	// complete.
+	dst, held := w, (*bytes.Buffer)(nil)
+	if strict {
+		held = new(bytes.Buffer)
+		dst = held
+	}
+
+	_, report, err := service.ConvertStream(dst, in, direction, c)
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "morse: %s: %v\n", name, err)
+		return ExitError
+	}
+
+	for _, issue := range report.Issues {
+		fmt.Fprintf(env.Stderr, "morse: %s:%v\n", name, issue)
+	}
+	if more := report.Total - len(report.Issues); more > 0 {
+		fmt.Fprintf(env.Stderr, "morse: %s: and %d more unknown symbols\n", name, more)
+	}
+	for _, corr := range report.Corrections {
+		fmt.Fprintf(env.Stderr, "morse: %s:%v\n", name, corr)
+	}
+
+	if strict && !report.Lossless() {
+		return ExitUnknown
+	}
+	if held != nil {
+		_, _ = held.WriteTo(w)
+	}
+	fmt.Fprintln(w)
+
+	if !report.Lossless() {
+		return ExitUnknown
+	}
+	return ExitOK
+}
+
+func wav(_ context.Context, args []string, env Env) int {
+	fs := flag.NewFlagSet("morse wav", flag.ContinueOnError)
+	var cf converterFlags
+	cf.register(fs)
+	output := fs.String("o", "", "write to this file instead of the standard output")
+	opts := service.DefaultAudioOptions
+	fs.Float64Var(&opts.WPM, "wpm", opts.WPM, "speed in words per minute")
+	fs.Float64Var(&opts.Farnsworth, "farnsworth", 0, "slower speed of the gaps, 0 keeps them at -wpm")
+	fs.Float64Var(&opts.Tone.Frequency, "freq", opts.Tone.Frequency, "tone frequency in Hz")
+	fs.Float64Var(&opts.Tone.Volume, "volume", opts.Tone.Volume, "volume from 0 to 1")
+	fs.IntVar(&opts.Tone.SampleRate, "sample-rate", opts.Tone.SampleRate, "samples per second")
+	cs := charsetFlag(fs)
+	if status, stop := parse(fs, args, env); stop {
+		return status
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintln(env.Stderr, "morse wav: at most one input file")
+		return ExitUsage
+	}
+
+	c, err := cf.converter(fs)
+	if err == nil {
+		_, err = charset.Lookup(*cs)
+	}
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "morse wav: %v\n", err)
+		return ExitUsage
+	}
+
+	in, name, err := open(inputs(fs.Args())[0], *cs, env)
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "morse: %v\n", err)
+		return ExitError
+	}
+	defer in.Close()
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "morse: %s: %v\n", name, err)
+		return ExitError
+	}
+
+	a, err := service.NewAudio(string(data), c, opts)
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "morse: %s: %v\n", name, err)
+		if errors.Is(err, morse.ErrInvalidTiming) || errors.Is(err, audio.ErrInvalidTone) {
+			return ExitUsage
+		}
+		return ExitError
+	}
+
+	return withOutput(*output, env, func(w io.Writer) int {
+		if err := a.Render(w); err != nil {
+			fmt.Fprintf(env.Stderr, "morse: write: %v\n", err)
+			return ExitError
+		}
+		return ExitOK
+	})
+}
+
+func inputs(args []string) []string {
+	if len(args) == 0 {
+		return []string{"-"}
+	}
+
+	return args
+}
+
+func charsetFlag(fs *flag.FlagSet) *string {
+	return fs.String("charset", "", "charset of the input: "+strings.Join(charset.Names, ", ")+" (default detected)")
+}
+
+// open opens a named input, "-" being the standard input, and transcodes
+// it to UTF-8 from cs.
+func open(file, cs string, env Env) (io.ReadCloser, string, error) {
+	var in io.ReadCloser = io.NopCloser(env.Stdin)
+	name := "stdin"
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, file, err
+		}
+		in, name = f, file
+	}
+
+	r, err := charset.NewReader(in, cs)
+	if err != nil {
+		_ = in.Close()
+		return nil, name, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, in}, name, nil
+}
+
+// withOutput runs write with the standard output, or with the named file
+// if there is one, and reports write errors. The file is written under a
+// temporary name next to it and only replaces the named file once write
+// has produced a result, so a failed run leaves an existing file intact.
+func withOutput(file string, env Env, write func(io.Writer) int) int {
+	if file == "" {
+		return buffered(env.Stdout, env, write)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "morse: %v\n", err)
+		return ExitError
+	}
+	defer os.Remove(f.Name())
+
+	status := buffered(f, env, write)
+	if err := f.Close(); err != nil && status != ExitError {
+		fmt.Fprintf(env.Stderr, "morse: write: %v\n", err)
+		return ExitError
+	}
+	if status == ExitError || status == ExitUsage {
+		return status
+	}
+
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(file); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(f.Name(), mode); err != nil {
+		fmt.Fprintf(env.Stderr, "morse: %v\n", err)
+		return ExitError
+	}
+	if err := os.Rename(f.Name(), file); err != nil {
+		fmt.Fprintf(env.Stderr, "morse: %v\n", err)
+		return ExitError
+	}
+
+	return status
+}
+
+func buffered(w io.Writer, env Env, write func(io.Writer) int) int {
+	bw := bufio.NewWriter(w)
+	status := write(bw)
+
+	if err := bw.Flush(); err != nil {
+		fmt.Fprintf(env.Stderr, "morse: write: %v\n", err)
+		return ExitError
+	}
+
+	return status
+}
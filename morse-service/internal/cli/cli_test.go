@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func run(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	status := Run(context.Background(), args, Env{
+		Stdin:  strings.NewReader(stdin),
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Getenv: func(string) string { return "" },
+	})
+
+	return status, stdout.String(), stderr.String()
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		stdin  string
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{"encode", "сос мир", []string{"encode"}, ExitOK, "... --- ...   -- .. .-.\n", ""},
+		{"decode", "... --- ...", []string{"decode"}, ExitOK, "СОС\n", ""},
+		{"auto", "... --- ...", []string{"auto"}, ExitOK, "СОС\n", ""},
+		{"latin", "SOS", []string{"encode", "-alphabet", "latin"}, ExitOK, "... --- ...\n", ""},
+		{"separators", "СОС МИР", []string{"encode", "-char-sep", "/", "-word-sep", " | "}, ExitOK,
+			".../---/... | --/../.-.\n", ""},
+		{"decode separators", ".../---/... | --/../.-.", []string{"decode", "-char-sep", "/", "-word-sep", " | "}, ExitOK,
+			"СОС МИР\n", ""},
+		{"trailing", "... ---", []string{"decode", "-trailing"}, ExitOK, "СО \n", ""},
+		{"notation", "СОС", []string{"encode", "-notation", "dit-dah"}, ExitOK, "di-di-dit dah-dah-dah di-di-dit\n", ""},
+		{"no lowercase", "сОс", []string{"encode", "-lowercase=false"}, ExitUnknown, "---\n", `stdin:1:1: No encoding for: "с"`},
+		{"prosigns", "<SK>", []string{"encode"}, ExitOK, "...-.-\n", ""},
+		{"no prosigns", "...-.-", []string{"decode", "-prosigns=false"}, ExitUnknown, "\n", `stdin:1:1: No encoding for: "...-.-"`},
+		{"decoding", "-..-", []string{"decode", "-decoding", "-..-=Ъ"}, ExitOK, "Ъ\n", ""},
+		{"correct", "....---.-.", []string{"decode", "-correct"}, ExitOK, "ХОР\n", `corrected to "ХОР"`},
+		{"unknown", "СОС!", []string{"encode", "-replace", "?"}, ExitUnknown, "... --- ... ?\n", `stdin:1:4: No encoding for: "!"`},
+		{"strict", "СОС!", []string{"encode", "-strict"}, ExitUnknown, "", `stdin:1:4`},
+		{"strict lossless", "СОС", []string{"encode", "-strict"}, ExitOK, "... --- ...\n", ""},
+		{"empty", " ", []string{"encode"}, ExitError, "", "stdin: пустые данные"},
+		{"windows-1251", "\xd1\xce\xd1", []string{"encode"}, ExitOK, "... --- ...\n", ""},
+		{"koi8-r", "\xf3\xef\xf3", []string{"encode", "-charset", "koi8-r"}, ExitOK, "... --- ...\n", ""},
+		{"unknown charset", "", []string{"encode", "-charset", "ebcdic"}, ExitUsage, "", `unknown charset "ebcdic"`},
+		{"unknown alphabet", "", []string{"encode", "-alphabet", "klingon"}, ExitUsage, "", "unknown alphabet"},
+		{"unknown notation", "", []string{"encode", "-notation", "smoke"}, ExitUsage, "", `unknown notation "smoke"`},
+		{"bad decoding", "", []string{"decode", "-decoding", "-..-"}, ExitUsage, "", "want code=letter"},
+		{"unknown flag", "", []string{"encode", "-loud"}, ExitUsage, "", "flag provided but not defined"},
+		{"help", "", []string{"encode", "-h"}, ExitOK, "", "-word-sep"},
+		{"missing file", "", []string{"encode", "no-such-file"}, ExitError, "", "no-such-file"},
+		{"no command", "", nil, ExitUsage, "", "usage: morse"},
+		{"unknown command", "", []string{"play"}, ExitUsage, "", `unknown command "play"`},
+		{"usage", "", []string{"help"}, ExitOK, "usage: morse", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, stdout, stderr := run(t, tt.stdin, tt.args...)
+
+			assert.Equal(t, tt.status, status, stderr)
+			if strings.HasPrefix(tt.stdout, "usage") {
+				assert.Contains(t, stdout, tt.stdout)
+			} else {
+				assert.Equal(t, tt.stdout, stdout)
+			}
+			if tt.stderr == "" {
+				assert.Empty(t, stderr)
+			} else {
+				assert.Contains(t, stderr, tt.stderr)
+			}
+		})
+	}
+}
+
+func TestConvertFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	out := filepath.Join(dir, "out.txt")
+	require.NoError(t, os.WriteFile(first, []byte("СОС\n"), 0o644))
+	require.NoError(t, os.WriteFile(second, []byte("МИР!"), 0o644))
+
+	status, stdout, stderr := run(t, "ДА", "encode", "-o", out, first, "-", second)
+
+	assert.Equal(t, ExitUnknown, status)
+	assert.Empty(t, stdout)
+	assert.Equal(t, "morse: "+second+`:1:4: No encoding for: "!"`+"\n", stderr)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "... --- ...\n-.. .-\n-- .. .-.\n", string(data))
+}
+
+func TestOutputKeptOnError(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	require.NoError(t, os.WriteFile(out, []byte("previous"), 0o600))
+
+	status, _, stderr := run(t, "", "encode", "-o", out, filepath.Join(dir, "missing.txt"))
+	assert.Equal(t, ExitError, status)
+	assert.NotEmpty(t, stderr)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "previous", string(data))
+
+	status, _, stderr = run(t, "СОС", "encode", "-o", out)
+	require.Equal(t, ExitOK, status, stderr)
+
+	info, err := os.Stat(out)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files are left behind")
+}
+
+func TestWAV(t *testing.T) {
+	status, stdout, stderr := run(t, "СОС", "wav", "-wpm", "30", "-freq", "700")
+	require.Equal(t, ExitOK, status, stderr)
+	assert.True(t, strings.HasPrefix(stdout, "RIFF"))
+
+	out := filepath.Join(t.TempDir(), "sos.wav")
+	status, _, stderr = run(t, "... --- ...", "wav", "-o", out)
+	require.Equal(t, ExitOK, status, stderr)
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "RIFF", string(data[:4]))
+
+	status, stdout, stderr = run(t, "СОС", "wav", "-wpm", "0")
+	assert.Equal(t, ExitUsage, status)
+	assert.Empty(t, stdout)
+	assert.NotEmpty(t, stderr)
+
+	status, _, _ = run(t, "", "wav", "a", "b")
+	assert.Equal(t, ExitUsage, status)
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var stdout, stderr bytes.Buffer
+	env := Env{Stdout: &stdout, Stderr: &stderr, Getenv: func(string) string { return "" }}
+
+	status := Serve(ctx, []string{"-addr", "127.0.0.1:0", "-output-dir", t.TempDir()}, env)
+	assert.Equal(t, ExitOK, status, stderr.String())
+	assert.Contains(t, stdout.String(), "shutdown complete")
+
+	status = Serve(ctx, []string{"-rate-limit", "-1"}, env)
+	assert.Equal(t, ExitUsage, status)
+	assert.Contains(t, stderr.String(), "invalid configuration")
+}
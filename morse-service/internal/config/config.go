@@ -0,0 +1,421 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"sprint6/internal/history"
+)
+
+// Config holds the service settings. Every setting can come from a YAML or
+// JSON file, an environment variable or a command-line flag; flags win over
+// the environment, which wins over the file, which wins over the defaults.
+type Config struct {
+	Addr          string
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	IdleTimeout   time.Duration
+	ShutdownDelay time.Duration
+	DrainTimeout  time.Duration
+	MaxUploadSize int64
+	OutputDir     string
+	StaticDir     string
+	LogLevel      string
+
+	HistoryBackend    string
+	HistoryMaxAge     time.Duration
+	HistoryMaxEntries int
+	HistoryAPI        bool
+
+	RateLimit      float64
+	RateBurst      int
+	MaxConversions int
+	TrustedProxies []netip.Prefix
+
+	MaxKeyerSessions int
+
+	BatchMaxEntries int
+	BatchMaxSize    int64
+}
+
+func Default() Config {
+	return Config{
+		Addr:          ":8080",
+		ReadTimeout:   5 * time.Second,
+		WriteTimeout:  10 * time.Second,
+		IdleTimeout:   15 * time.Second,
+		ShutdownDelay: 0,
+		DrainTimeout:  15 * time.Second,
+		MaxUploadSize: 10 << 20,
+		OutputDir:     ".",
+		StaticDir:     ".",
+		LogLevel:      "info",
+
+		HistoryBackend:    "file",
+		HistoryMaxAge:     30 * 24 * time.Hour,
+		HistoryMaxEntries: 1000,
+
+		RateLimit:      5,
+		RateBurst:      20,
+		MaxConversions: 16,
+
+		MaxKeyerSessions: 64,
+
+		BatchMaxEntries: 1000,
+		BatchMaxSize:    50 << 20,
+	}
+}
+
+const (
+	envPrefix  = "MORSE_"
+	configFlag = "config"
+)
+
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+type setting struct {
+	key   string
+	usage string
+	set   func(*Config, string) error
+}
+
+var settings = []setting{
+	{"addr", "listen address", func(c *Config, v string) error {
+		c.Addr = v
+		return nil
+	}},
+	{"read-timeout", "maximum duration for reading a request", durationSetter(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{"write-timeout", "maximum duration for writing a response", durationSetter(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{"idle-timeout", "keep-alive idle timeout", durationSetter(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{"shutdown-delay", "how long to report not ready before shutting down", durationSetter(func(c *Config) *time.Duration { return &c.ShutdownDelay })},
+	{"drain-timeout", "maximum duration for in-flight requests on shutdown", durationSetter(func(c *Config) *time.Duration { return &c.DrainTimeout })},
+	{"max-upload-size", "maximum request body size, e.g. 10MB", func(c *Config, v string) error {
+		size, err := parseSize(v)
+		c.MaxUploadSize = size
+		return err
+	}},
+	{"output-dir", "directory for the conversion history", func(c *Config, v string) error {
+		c.OutputDir = v
+		return nil
+	}},
+	{"static-dir", "directory with index.html", func(c *Config, v string) error {
+		c.StaticDir = v
+		return nil
+	}},
+	{"log-level", "log level: " + strings.Join(LogLevels, ", "), func(c *Config, v string) error {
+		c.LogLevel = strings.ToLower(v)
+		return nil
+	}},
+	{"history-backend", "conversion history storage: " + strings.Join(history.Backends, ", "), func(c *Config, v string) error {
+		c.HistoryBackend = strings.ToLower(v)
+		return nil
+	}},
+	{"history-max-age", "remove history entries older than this, 0 keeps them", durationSetter(func(c *Config) *time.Duration { return &c.HistoryMaxAge })},
+	{"history-max-entries", "number of history entries to keep, 0 keeps all", func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		c.HistoryMaxEntries = n
+		return err
+	}},
+	{"history-api", "serve the history, with everything uploaded, at /history without authentication", func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		c.HistoryAPI = b
+		return err
+	}},
+	{"rate-limit", "conversion requests per second per client IP, 0 disables the limit", func(c *Config, v string) error {
+		r, err := strconv.ParseFloat(v, 64)
+		c.RateLimit = r
+		return err
+	}},
+	{"rate-burst", "conversion requests a client IP may make at once", func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		c.RateBurst = n
+		return err
+	}},
+	{"max-conversions", "conversions served at the same time, 0 for no limit", func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		c.MaxConversions = n
+		return err
+	}},
+	{"trusted-proxies", "comma-separated addresses or CIDR ranges of reverse proxies whose X-Forwarded-For names the client", func(c *Config, v string) error {
+		proxies, err := parseProxies(v)
+		c.TrustedProxies = proxies
+		return err
+	}},
+	{"max-keyer-sessions", "keyer WebSocket sessions open at the same time, 0 for no limit", func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		c.MaxKeyerSessions = n
+		return err
+	}},
+	{"batch-max-entries", "entries a batch archive may hold", func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		c.BatchMaxEntries = n
+		return err
+	}},
+	{"batch-max-size", "total decompressed size of a batch archive, e.g. 50MB", func(c *Config, v string) error {
+		size, err := parseSize(v)
+		c.BatchMaxSize = size
+		return err
+	}},
+}
+
+func durationSetter(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		*field(c) = d
+		return err
+	}
+}
+
+// Load builds the configuration from command-line arguments, the
+// environment and the config file named by -config or MORSE_CONFIG.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	fs := flag.NewFlagSet("morse-service", flag.ContinueOnError)
+
+	path := fs.String(configFlag, getenv(envVar(configFlag)), "path to a YAML or JSON config file")
+
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		fs.Func(s.key, s.usage, func(v string) error {
+			flagValues[s.key] = v
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() != 0 {
+		return Config{}, fmt.Errorf("unexpected arguments: %q", fs.Args())
+	}
+
+	cfg := Default()
+
+	var errs []error
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, s := range settings {
+		if v := getenv(envVar(s.key)); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", envVar(s.key), err))
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := flagValues[s.key]; ok {
+			if err := s.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.key, err))
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func envVar(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// loadFile applies a YAML file. JSON is valid YAML, so it is read the same way.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	var errs []error
+	for key, value := range values {
+		i := slices.IndexFunc(settings, func(s setting) bool { return s.key == key })
+		if i < 0 {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+
+		// Lists are read like the comma-separated flag values.
+		if list, ok := value.([]any); ok {
+			parts := make([]string, len(list))
+			for i, v := range list {
+				parts[i] = fmt.Sprint(v)
+			}
+			value = strings.Join(parts, ",")
+		}
+
+		if err := settings[i].set(c, fmt.Sprint(value)); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate reports all invalid settings at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr: %w", err))
+	}
+
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"read-timeout", c.ReadTimeout},
+		{"write-timeout", c.WriteTimeout},
+		{"idle-timeout", c.IdleTimeout},
+		{"drain-timeout", c.DrainTimeout},
+	} {
+		if t.d <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be positive, got %v", t.name, t.d))
+		}
+	}
+
+	if c.ShutdownDelay < 0 {
+		errs = append(errs, fmt.Errorf("shutdown-delay: must not be negative, got %v", c.ShutdownDelay))
+	}
+
+	if c.MaxUploadSize <= 0 {
+		errs = append(errs, fmt.Errorf("max-upload-size: must be positive, got %d", c.MaxUploadSize))
+	}
+
+	if err := checkDir(c.OutputDir); err != nil {
+		errs = append(errs, fmt.Errorf("output-dir: %w", err))
+	}
+
+	if err := checkDir(c.StaticDir); err != nil {
+		errs = append(errs, fmt.Errorf("static-dir: %w", err))
+	}
+
+	if !slices.Contains(LogLevels, c.LogLevel) {
+		errs = append(errs, fmt.Errorf("log-level: unknown level %q", c.LogLevel))
+	}
+
+	if !slices.Contains(history.Backends, c.HistoryBackend) {
+		errs = append(errs, fmt.Errorf("history-backend: unknown backend %q", c.HistoryBackend))
+	}
+
+	if c.HistoryMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("history-max-age: must not be negative, got %v", c.HistoryMaxAge))
+	}
+
+	if c.HistoryMaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("history-max-entries: must not be negative, got %d", c.HistoryMaxEntries))
+	}
+
+	if c.RateLimit < 0 || math.IsNaN(c.RateLimit) || math.IsInf(c.RateLimit, 0) {
+		errs = append(errs, fmt.Errorf("rate-limit: must be a non-negative number, got %v", c.RateLimit))
+	}
+
+	if c.RateLimit > 0 && c.RateBurst < 1 {
+		errs = append(errs, fmt.Errorf("rate-burst: must be at least 1, got %d", c.RateBurst))
+	}
+
+	if c.MaxConversions < 0 {
+		errs = append(errs, fmt.Errorf("max-conversions: must not be negative, got %d", c.MaxConversions))
+	}
+
+	if c.MaxKeyerSessions < 0 {
+		errs = append(errs, fmt.Errorf("max-keyer-sessions: must not be negative, got %d", c.MaxKeyerSessions))
+	}
+
+	if c.BatchMaxEntries <= 0 {
+		errs = append(errs, fmt.Errorf("batch-max-entries: must be positive, got %d", c.BatchMaxEntries))
+	}
+
+	if c.BatchMaxSize <= 0 {
+		errs = append(errs, fmt.Errorf("batch-max-size: must be positive, got %d", c.BatchMaxSize))
+	}
+
+	return errors.Join(errs...)
+}
+
+func checkDir(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	return nil
+}
+
+// parseProxies parses comma-separated IP addresses and CIDR ranges.
+func parseProxies(v string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for part := range strings.SplitSeq(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if strings.Contains(part, "/") {
+			p, err := netip.ParsePrefix(part)
+			if err != nil {
+				return nil, err
+			}
+			proxies = append(proxies, p.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(part)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return proxies, nil
+}
+
+// parseSize parses a byte count with an optional KB, MB or GB suffix; the
+// multiples are binary.
+func parseSize(v string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(v))
+
+	shift := 0
+	for _, u := range []struct {
+		suffix string
+		shift  int
+	}{{"KB", 10}, {"MB", 20}, {"GB", 30}, {"B", 0}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, shift = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.shift
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+	if n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("size %q is too large", v)
+	}
+
+	return n << shift, nil
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func staticDir(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), nil, 0o644))
+	return dir
+}
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Chdir(staticDir(t))
+
+	cfg, err := Load(nil, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	static := staticDir(t)
+	out := t.TempDir()
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+addr: ":9000"
+read-timeout: 1m
+max-upload-size: 1MB
+output-dir: `+out+`
+static-dir: `+static+`
+log-level: warn
+history-backend: sqlite
+history-max-entries: 10
+history-api: true
+rate-limit: 0.5
+batch-max-size: 5MB
+trusted-proxies: [10.0.0.0/8, "::1"]
+`), 0o644))
+
+	cfg, err := Load(
+		[]string{"-config", file, "-addr", "127.0.0.1:9100"},
+		env(map[string]string{"MORSE_ADDR": ":9200", "MORSE_LOG_LEVEL": "DEBUG", "MORSE_IDLE_TIMEOUT": "2s", "MORSE_HISTORY_MAX_ENTRIES": "20", "MORSE_MAX_CONVERSIONS": "4", "MORSE_MAX_KEYER_SESSIONS": "8", "MORSE_BATCH_MAX_ENTRIES": "100"}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, Config{
+		Addr:          "127.0.0.1:9100",
+		ReadTimeout:   time.Minute,
+		WriteTimeout:  10 * time.Second,
+		IdleTimeout:   2 * time.Second,
+		DrainTimeout:  15 * time.Second,
+		MaxUploadSize: 1 << 20,
+		OutputDir:     out,
+		StaticDir:     static,
+		LogLevel:      "debug",
+
+		HistoryBackend:    "sqlite",
+		HistoryMaxAge:     30 * 24 * time.Hour,
+		HistoryMaxEntries: 20,
+		HistoryAPI:        true,
+
+		RateLimit:      0.5,
+		RateBurst:      20,
+		MaxConversions: 4,
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")},
+
+		MaxKeyerSessions: 8,
+
+		BatchMaxEntries: 100,
+		BatchMaxSize:    5 << 20,
+	}, cfg)
+}
+
+func TestLoadJSONFileFromEnv(t *testing.T) {
+	static := staticDir(t)
+
+	file := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"static-dir": "`+static+`", "max-upload-size": 4096}`), 0o644))
+
+	cfg, err := Load(nil, env(map[string]string{"MORSE_CONFIG": file}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4096), cfg.MaxUploadSize)
+	assert.Equal(t, static, cfg.StaticDir)
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Chdir(staticDir(t))
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("colour: red\n"), 0o644))
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want []string
+	}{
+		{"bad duration", []string{"-read-timeout", "soon"}, nil, []string{"-read-timeout"}},
+		{"bad size", nil, map[string]string{"MORSE_MAX_UPLOAD_SIZE": "lots"}, []string{"MORSE_MAX_UPLOAD_SIZE"}},
+		{"unknown key", []string{"-config", file}, nil, []string{`unknown setting "colour"`}},
+		{"missing file", []string{"-config", "/nonexistent.yaml"}, nil, []string{"config file"}},
+		{
+			"validation",
+			[]string{"-addr", "8080", "-write-timeout", "0s", "-shutdown-delay", "-1s", "-log-level", "loud", "-output-dir", "/nonexistent"},
+			nil,
+			[]string{"addr:", "write-timeout:", "shutdown-delay:", "log-level:", "output-dir:"},
+		},
+		{
+			"history",
+			[]string{"-history-backend", "redis", "-history-max-entries", "-1"},
+			map[string]string{"MORSE_HISTORY_MAX_AGE": "-1h"},
+			[]string{"history-backend:", "history-max-entries:", "history-max-age:"},
+		},
+		{
+			"rate limit",
+			[]string{"-rate-limit", "-2", "-max-conversions", "-1", "-max-keyer-sessions", "-1"},
+			nil,
+			[]string{"rate-limit:", "max-conversions:", "max-keyer-sessions:"},
+		},
+		{"rate burst", nil, map[string]string{"MORSE_RATE_BURST": "0"}, []string{"rate-burst:"}},
+		{"bad history api", []string{"-history-api", "maybe"}, nil, []string{"-history-api"}},
+		{"bad rate", []string{"-rate-limit", "fast"}, nil, []string{"-rate-limit"}},
+		{"bad proxy", nil, map[string]string{"MORSE_TRUSTED_PROXIES": "10.0.0.1, proxy.local"}, []string{"MORSE_TRUSTED_PROXIES"}},
+		{
+			"batch",
+			[]string{"-batch-max-entries", "0", "-batch-max-size", "0"},
+			nil,
+			[]string{"batch-max-entries:", "batch-max-size:"},
+		},
+		{"arguments", []string{"extra"}, nil, []string{"unexpected arguments"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args, env(tt.env))
+			require.Error(t, err)
+			for _, want := range tt.want {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{"100": 100, "10MB": 10 << 20, "2 kb": 2 << 10, "1GB": 1 << 30, "5B": 5} {
+		got, err := parseSize(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	_, err := parseSize("9999999999GB")
+	require.Error(t, err)
+}
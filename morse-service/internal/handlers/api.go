@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"sprint6/internal/service"
+	"sprint6/pkg/morse"
+)
+
+type ConvertRequest struct {
+	Text          string            `json:"text"`
+	Direction     service.Direction `json:"direction"`
+	Alphabet      string            `json:"alphabet"`
+	CharSeparator string            `json:"charSeparator"`
+	WordSeparator string            `json:"wordSeparator"`
+	Notation      string            `json:"notation"`
+	Correct       bool              `json:"correct"`
+}
+
+type Warning struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Offset  int    `json:"offset"`
+	Text    string `json:"text"`
+	Message string `json:"message"`
+}
+
+type Correction struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+	Code   string `json:"code"`
+	Text   string `json:"text"`
+}
+
+type ConvertResponse struct {
+	Result       string            `json:"result"`
+	Direction    service.Direction `json:"direction"`
+	Confidence   float64           `json:"confidence"`
+	Notation     string            `json:"notation"`
+	Alphabet     string            `json:"alphabet"`
+	Warnings     []Warning         `json:"warnings"`
+	WarningCount int               `json:"warningCount"`
+	Corrections  []Correction      `json:"corrections,omitempty"`
+	Charset      string            `json:"charset,omitempty"`
+	HistoryID    string            `json:"historyId,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Convert is the JSON counterpart of Upload.
+func (h *Handlers) Convert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	var (
+		req  ConvertRequest
+		conv morse.Converter
+	)
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSON(w, status, errorResponse{Error: fmt.Sprintf("decode request error: %v", err)})
+		return
+	}
+
+	options, err := req.options()
+	if err == nil {
+		conv, err = service.Converter(req.Alphabet, options...)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("converter error: %v", err)})
+		return
+	}
+
+	res, err := service.Convert(req.Text, req.Direction, conv)
+	if err != nil {
+		writeJSON(w, convertStatus(err), errorResponse{Error: fmt.Sprintf("convert error: %v", err)})
+		return
+	}
+
+	alphabet := req.Alphabet
+	if alphabet == "" {
+		alphabet = morse.DefaultAlphabet
+	}
+	h.cfg.Metrics.conversion(res.Direction, alphabet, len(req.Text), len(res.Output), res.Report)
+
+	writeJSON(w, http.StatusOK, ConvertResponse{
+		Result:       res.Output,
+		Direction:    res.Direction,
+		Confidence:   res.Confidence,
+		Notation:     res.Notation.Name(),
+		Alphabet:     alphabet,
+		Warnings:     warnings(res.Report),
+		WarningCount: res.Report.Total,
+		Corrections:  corrections(res.Report),
+	})
+}
+
+func (req ConvertRequest) options() ([]morse.ConverterOption, error) {
+	options, err := notationOptions(req.Notation)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CharSeparator != "" {
+		options = append(options, morse.WithCharSeparator(req.CharSeparator))
+	}
+	if req.WordSeparator != "" {
+		options = append(options, morse.WithWordSeparator(req.WordSeparator))
+	}
+	if req.Correct {
+		options = append(options, correction)
+	}
+
+	return options, nil
+}
+
+func warnings(report morse.ConversionReport) []Warning {
+	out := make([]Warning, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		out = append(out, Warning{
+			Line:    issue.Line,
+			Column:  issue.Column,
+			Offset:  issue.Offset,
+			Text:    issue.Err.Text,
+			Message: issue.Error(),
+		})
+	}
+
+	return out
+}
+
+func corrections(report morse.ConversionReport) []Correction {
+	if len(report.Corrections) == 0 {
+		return nil
+	}
+
+	out := make([]Correction, 0, len(report.Corrections))
+	for _, c := range report.Corrections {
+		out = append(out, Correction{
+			Line:   c.Line,
+			Column: c.Column,
+			Offset: c.Offset,
+			Code:   c.Code,
+			Text:   c.Text,
+		})
+	}
+
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
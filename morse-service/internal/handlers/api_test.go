@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/internal/archive"
+	"sprint6/internal/history"
+)
+
+func testConfig(t *testing.T) Config {
+	store, err := history.NewFileStore(t.TempDir(), history.Retention{})
+	require.NoError(t, err)
+
+	return Config{
+		MaxUploadSize: 1 << 20,
+		StaticDir:     "../..",
+		History:       store,
+		BatchLimits:   archive.Limits{MaxEntries: 10, MaxSize: 1 << 20},
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   ConvertResponse
+	}{
+		{
+			name:   "auto encode",
+			body:   `{"text": "СОС"}`,
+			status: http.StatusOK,
+			want:   ConvertResponse{Result: "... --- ...", Direction: "encode", Confidence: 1, Notation: "standard", Alphabet: "russian", Warnings: []Warning{}},
+		},
+		{
+			name:   "auto decode latin",
+			body:   `{"text": "... --- ...", "alphabet": "latin"}`,
+			status: http.StatusOK,
+			want:   ConvertResponse{Result: "SOS", Direction: "decode", Confidence: 1, Notation: "standard", Alphabet: "latin", Warnings: []Warning{}},
+		},
+		{
+			name:   "forced decode with separator",
+			body:   `{"text": "...|---|...", "direction": "decode", "charSeparator": "|"}`,
+			status: http.StatusOK,
+			want:   ConvertResponse{Result: "СОС", Direction: "decode", Confidence: 1, Notation: "standard", Alphabet: "russian", Warnings: []Warning{}},
+		},
+		{
+			name:   "word separator",
+			body:   `{"text": "СОС мир", "wordSeparator": " / "}`,
+			status: http.StatusOK,
+			want:   ConvertResponse{Result: "... --- ... / -- .. .-.", Direction: "encode", Confidence: 1, Notation: "standard", Alphabet: "russian", Warnings: []Warning{}},
+		},
+		{
+			name:   "decode word separator",
+			body:   `{"text": "... --- ... # -- .. .-.", "wordSeparator": " # "}`,
+			status: http.StatusOK,
+			want:   ConvertResponse{Result: "СОС МИР", Direction: "decode", Confidence: 1, Notation: "standard", Alphabet: "russian", Warnings: []Warning{}},
+		},
+		{
+			name:   "warnings",
+			body:   `{"text": "\nДаQ"}`,
+			status: http.StatusOK,
+			want: ConvertResponse{
+				Result:     "-.. .-",
+				Direction:  "encode",
+				Confidence: 1,
+				Notation:   "standard",
+				Alphabet:   "russian",
+				Warnings: []Warning{
+					{Line: 2, Column: 3, Offset: 5, Text: "Q", Message: `2:3: No encoding for: "Q"`},
+				},
+				WarningCount: 1,
+			},
+		},
+		{
+			name:   "auto decode with a stray letter",
+			body:   `{"text": "... --- ... x ... --- ..."}`,
+			status: http.StatusOK,
+			want: ConvertResponse{
+				Result:     "СОССОС",
+				Direction:  "decode",
+				Confidence: 18.0 / 19,
+				Notation:   "standard",
+				Alphabet:   "russian",
+				Warnings: []Warning{
+					{Line: 1, Column: 13, Offset: 12, Text: "x", Message: `1:13: No encoding for: "x"`},
+				},
+				WarningCount: 1,
+			},
+		},
+		{
+			name:   "encode in a notation",
+			body:   `{"text": "СОС", "notation": "dit-dah"}`,
+			status: http.StatusOK,
+			want: ConvertResponse{
+				Result:     "di-di-dit dah-dah-dah di-di-dit",
+				Direction:  "encode",
+				Confidence: 1,
+				Notation:   "dit-dah",
+				Alphabet:   "russian",
+				Warnings:   []Warning{},
+			},
+		},
+		{
+			name:   "auto decode a notation",
+			body:   `{"text": "··· −−− ···"}`,
+			status: http.StatusOK,
+			want: ConvertResponse{
+				Result:     "СОС",
+				Direction:  "decode",
+				Confidence: 1,
+				Notation:   "unicode",
+				Alphabet:   "russian",
+				Warnings:   []Warning{},
+			},
+		},
+		{
+			name:   "correct",
+			body:   `{"text": "....---.-. ---", "direction": "decode", "correct": true}`,
+			status: http.StatusOK,
+			want: ConvertResponse{
+				Result:     "ХОРО",
+				Direction:  "decode",
+				Confidence: 1,
+				Notation:   "standard",
+				Alphabet:   "russian",
+				Warnings:   []Warning{},
+				Corrections: []Correction{
+					{Line: 1, Column: 1, Offset: 0, Code: "....---.-.", Text: "ХОР"},
+				},
+			},
+		},
+		{name: "bad notation", body: `{"text": "a", "notation": "smoke"}`, status: http.StatusBadRequest},
+		{name: "ambiguous", body: `{"text": "ab ..."}`, status: http.StatusUnprocessableEntity},
+		{name: "empty", body: `{"text": "  "}`, status: http.StatusBadRequest},
+		{name: "bad direction", body: `{"text": "a", "direction": "up"}`, status: http.StatusBadRequest},
+		{name: "bad alphabet", body: `{"text": "a", "alphabet": "klingon"}`, status: http.StatusBadRequest},
+		{name: "unknown field", body: `{"txt": "a"}`, status: http.StatusBadRequest},
+		{name: "not json", body: `text=a`, status: http.StatusBadRequest},
+		{name: "too large", body: `{"text": "` + strings.Repeat("a", 1<<20) + `"}`, status: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/convert", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			New(testConfig(t)).Convert(rec, req)
+
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+			assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+			if tt.status != http.StatusOK {
+				var resp errorResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+				assert.NotEmpty(t, resp.Error)
+				return
+			}
+
+			var resp ConvertResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tt.want, resp)
+		})
+	}
+}
+
+func TestConvertMethod(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New(testConfig(t)).Convert(rec, httptest.NewRequest(http.MethodGet, "/api/v1/convert", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
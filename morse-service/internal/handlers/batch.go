@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"sprint6/internal/archive"
+	"sprint6/internal/charset"
+	"sprint6/internal/middleware"
+	"sprint6/internal/service"
+	"sprint6/pkg/morse"
+)
+
+// manifestName is the manifest of a batch result, at the archive root.
+const manifestName = "manifest.json"
+
+// BatchManifest describes every file of a batch archive: how it was
+// converted, or why it was not.
+type BatchManifest struct {
+	Archive   string         `json:"archive"`
+	Format    archive.Format `json:"format"`
+	Alphabet  string         `json:"alphabet"`
+	Files     []BatchFile    `json:"files"`
+	Converted int            `json:"converted"`
+	Failed    int            `json:"failed"`
+}
+
+type BatchFile struct {
+	Name         string            `json:"name"`
+	Output       string            `json:"output,omitempty"`
+	Direction    service.Direction `json:"direction,omitempty"`
+	Size         int               `json:"size"`
+	OutputSize   int               `json:"outputSize"`
+	Warnings     []Warning         `json:"warnings,omitempty"`
+	WarningCount int               `json:"warningCount"`
+	Corrections  []Correction      `json:"corrections,omitempty"`
+	Charset      string            `json:"charset,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// Batch converts every text file of an uploaded ZIP or tar.gz archive and
+// responds with an archive in the same format, holding the results next
+// to where the files were and manifest.json. A file that cannot be
+// converted is only noted in the manifest. The form fields are those of
+// Upload; batch results do not go to the history.
+func (h *Handlers) Batch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = w.Write([]byte("method not allowed"))
+		return
+	}
+
+	if !h.parseForm(w, r, false) {
+		return
+	}
+
+	conv, alphabet, err := uploadConverter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := batchOptions{
+		direction: service.Direction(r.FormValue("direction")),
+		conv:      conv,
+		alphabet:  alphabet,
+	}
+	switch opts.direction {
+	case "", service.DirectionAuto, service.DirectionEncode, service.DirectionDecode:
+	default:
+		http.Error(w, fmt.Sprintf("convert error: %v: %q", service.ErrInvalidDirection, opts.direction), http.StatusBadRequest)
+		return
+	}
+	if opts.charset, err = charset.Lookup(r.FormValue("charset")); err != nil {
+		http.Error(w, fmt.Sprintf("charset error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("myFile")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("read form file error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	in, err := archive.NewReader(file, header.Size, h.cfg.BatchLimits)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("archive error: %v", err), archiveStatus(err))
+		return
+	}
+
+	// The results are spooled to disk: they are larger than the input,
+	// and the response must not start before the archive is known to be
+	// within the limits.
+	tmp, err := os.CreateTemp("", "morse-batch-*")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("batch error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	manifest := BatchManifest{Archive: header.Filename, Format: in.Format(), Alphabet: alphabet, Files: []BatchFile{}}
+	out := archive.NewWriter(tmp, in.Format())
+	used := map[string]bool{manifestName: true}
+
+	for {
+		f, err := in.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("archive error: %v", err), archiveStatus(err))
+			return
+		}
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("archive error: %s: %v", f.Name, err), archiveStatus(err))
+			return
+		}
+
+		result, output := h.convertBatchFile(f.Name, data, opts, used)
+		if result.Error == "" {
+			err = out.WriteFile(result.Output, output)
+			manifest.Converted++
+		} else {
+			manifest.Failed++
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("batch error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		manifest.Files = append(manifest.Files, result)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = out.WriteFile(manifestName, data)
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	var size int64
+	if err == nil {
+		size, err = tmp.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("batch error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	middleware.Logger(r.Context()).Debug("batch converted",
+		"archive", header.Filename,
+		"format", manifest.Format,
+		"converted", manifest.Converted,
+		"failed", manifest.Failed)
+
+	w.Header().Set("Content-Type", manifest.Format.ContentType())
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": batchFilename(header.Filename, manifest.Format),
+	}))
+	_, _ = io.Copy(w, tmp)
+}
+
+// batchOptions are the form fields of a batch, applied to every file.
+type batchOptions struct {
+	direction service.Direction
+	conv      morse.Converter
+	alphabet  string
+	charset   string
+}
+
+// convertBatchFile converts one file of a batch; a failure is reported in
+// the Error of the result. used holds the output names taken so far.
+func (h *Handlers) convertBatchFile(name string, data []byte, opts batchOptions, used map[string]bool) (BatchFile, []byte) {
+	result := BatchFile{Name: name, Size: len(data)}
+
+	clean, ok := archive.CleanName(name)
+	if !ok {
+		result.Error = "unsafe file name"
+		return result, nil
+	}
+
+	src, err := charset.NewReader(bytes.NewReader(data), opts.charset)
+	if err != nil {
+		result.Error = fmt.Sprintf("charset error: %v", err)
+		return result, nil
+	}
+	text, err := io.ReadAll(src)
+	if err != nil {
+		result.Error = charset.ErrNotText.Error()
+		return result, nil
+	}
+	result.Charset = src.Charset()
+
+	res, err := service.Convert(string(text), opts.direction, opts.conv)
+	if err != nil {
+		result.Error = fmt.Sprintf("convert error: %v", err)
+		return result, nil
+	}
+
+	h.cfg.Metrics.conversion(res.Direction, opts.alphabet, len(text), len(res.Output), res.Report)
+
+	result.Output = uniqueName(path.Join(path.Dir(clean), resultFilename(clean, res.Direction, mediaText)), used)
+	result.Direction = res.Direction
+	result.OutputSize = len(res.Output)
+	result.Warnings = warnings(res.Report)
+	result.WarningCount = res.Report.Total
+	result.Corrections = corrections(res.Report)
+
+	return result, []byte(res.Output)
+}
+
+// uniqueName numbers name, e.g. a.morse-2.txt, if it is already used, and
+// marks the result used.
+func uniqueName(name string, used map[string]bool) string {
+	base, ext := strings.TrimSuffix(name, path.Ext(name)), path.Ext(name)
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	used[name] = true
+
+	return name
+}
+
+// batchFilename is the download name of a batch result, e.g. letters.zip
+// becomes letters.morse.zip.
+func batchFilename(original string, format archive.Format) string {
+	base := path.Base(strings.ReplaceAll(original, `\`, "/"))
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+		if len(base) > len(ext) && strings.EqualFold(base[len(base)-len(ext):], ext) {
+			base = base[:len(base)-len(ext)]
+			break
+		}
+	}
+	if base == "" || base == "." || base == "/" {
+		base = "result"
+	}
+
+	return base + ".morse" + format.Ext()
+}
+
+// archiveStatus maps an error reading an uploaded archive to the HTTP
+// status of its response; a damaged archive is the client's.
+func archiveStatus(err error) int {
+	switch {
+	case errors.Is(err, archive.ErrUnsupported):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, archive.ErrTooManyEntries), errors.Is(err, archive.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusBadRequest
+	}
+}
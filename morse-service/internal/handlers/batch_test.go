@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/internal/archive"
+	"sprint6/internal/service"
+)
+
+func testArchive(t *testing.T, format archive.Format, files map[string]string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := archive.NewWriter(&buf, format)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		require.NoError(t, w.WriteFile(name, []byte(files[name])))
+	}
+	require.NoError(t, w.Close())
+
+	return buf.String()
+}
+
+func readArchive(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	r, err := archive.NewReader(bytes.NewReader(data), int64(len(data)), archive.Limits{MaxEntries: 100, MaxSize: 1 << 20})
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(f)
+		require.NoError(t, err)
+		files[f.Name] = string(b)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	for _, tt := range []struct {
+		format      archive.Format
+		upload      string
+		contentType string
+		filename    string
+	}{
+		{archive.Zip, "letters.zip", "application/zip", "letters.morse.zip"},
+		{archive.TarGz, "letters.tar.gz", "application/gzip", "letters.morse.tar.gz"},
+	} {
+		t.Run(string(tt.format), func(t *testing.T) {
+			h := New(testConfig(t))
+
+			upload := testArchive(t, tt.format, map[string]string{
+				"sos.txt":          "СОС",
+				"windows.txt":      "\xd1\xce\xd1",
+				"dir/reply.txt":    "-- .. .-.",
+				"dir/reply.md":     ".--. .-. .. .-- . -",
+				"unknown.txt":      "СОС!",
+				"empty.txt":        "  ",
+				"image.png":        "\x89PNG\r\n\x1a\n\x00",
+				"../../escape.txt": "СОС",
+			})
+			rec := httptest.NewRecorder()
+			h.Batch(rec, uploadRequest(t, tt.upload, upload, nil))
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			assert.Contains(t, rec.Header().Get("Content-Disposition"), tt.filename)
+
+			files := readArchive(t, rec.Body.Bytes())
+
+			var manifest BatchManifest
+			require.NoError(t, json.Unmarshal([]byte(files["manifest.json"]), &manifest))
+			delete(files, "manifest.json")
+
+			assert.Equal(t, map[string]string{
+				"sos.morse.txt":        "... --- ...",
+				"dir/reply.text.txt":   "ПРИВЕТ",
+				"dir/reply.text-2.txt": "МИР",
+				"unknown.morse.txt":    "... --- ...",
+				"windows.morse.txt":    "... --- ...",
+			}, files)
+
+			assert.Equal(t, tt.upload, manifest.Archive)
+			assert.Equal(t, tt.format, manifest.Format)
+			assert.Equal(t, "russian", manifest.Alphabet)
+			assert.Equal(t, 5, manifest.Converted)
+			assert.Equal(t, 3, manifest.Failed)
+			require.Len(t, manifest.Files, 8)
+
+			byName := map[string]BatchFile{}
+			for _, f := range manifest.Files {
+				byName[f.Name] = f
+			}
+
+			assert.Equal(t, BatchFile{
+				Name:       "sos.txt",
+				Output:     "sos.morse.txt",
+				Direction:  service.DirectionEncode,
+				Size:       len("СОС"),
+				OutputSize: len("... --- ..."),
+				Charset:    "utf-8",
+			}, byName["sos.txt"])
+			assert.Equal(t, "windows-1251", byName["windows.txt"].Charset)
+			assert.Equal(t, service.DirectionDecode, byName["dir/reply.txt"].Direction)
+
+			unknown := byName["unknown.txt"]
+			assert.Equal(t, 1, unknown.WarningCount)
+			require.Len(t, unknown.Warnings, 1)
+			assert.Equal(t, "!", unknown.Warnings[0].Text)
+
+			assert.Contains(t, byName["empty.txt"].Error, "convert error")
+			assert.Equal(t, "not a text file", byName["image.png"].Error)
+			assert.Equal(t, "unsafe file name", byName["../../escape.txt"].Error)
+		})
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	h := New(testConfig(t))
+
+	files := map[string]string{}
+	for i := range 11 {
+		files[strings.Repeat("f", i+1)] = "СОС"
+	}
+	tooMany := testArchive(t, archive.Zip, files)
+	tooLarge := testArchive(t, archive.TarGz, map[string]string{"big.txt": strings.Repeat("С", 1<<20)})
+
+	tests := []struct {
+		name    string
+		content string
+		fields  map[string]string
+		status  int
+	}{
+		{"not an archive", "СОС", nil, http.StatusUnsupportedMediaType},
+		{"broken", "PK\x03\x04 broken", nil, http.StatusBadRequest},
+		{"too many entries", tooMany, nil, http.StatusRequestEntityTooLarge},
+		{"too large", tooLarge, nil, http.StatusRequestEntityTooLarge},
+		{"unknown alphabet", tooMany, map[string]string{"alphabet": "klingon"}, http.StatusBadRequest},
+		{"bad direction", tooMany, map[string]string{"direction": "sideways"}, http.StatusBadRequest},
+		{"unknown charset", tooMany, map[string]string{"charset": "ebcdic"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Batch(rec, uploadRequest(t, "in.zip", tt.content, tt.fields))
+
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+			assert.NotEqual(t, "application/zip", rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestBatchFilename(t *testing.T) {
+	tests := []struct {
+		original string
+		format   archive.Format
+		want     string
+	}{
+		{"letters.zip", archive.Zip, "letters.morse.zip"},
+		{"letters.TAR.GZ", archive.TarGz, "letters.morse.tar.gz"},
+		{"letters.tgz", archive.TarGz, "letters.morse.tar.gz"},
+		{`C:\Users\me\letters.zip`, archive.Zip, "letters.morse.zip"},
+		{"upload", archive.Zip, "upload.morse.zip"},
+		{".zip", archive.Zip, ".zip.morse.zip"},
+		{"", archive.TarGz, "result.morse.tar.gz"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, batchFilename(tt.original, tt.format), tt.original)
+	}
+}
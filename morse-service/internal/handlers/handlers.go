@@ -1,16 +1,90 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sprint6/internal/archive"
+	"sprint6/internal/charset"
+	"sprint6/internal/history"
+	"sprint6/internal/middleware"
 	"sprint6/internal/service"
+	"sprint6/pkg/audio"
+	"sprint6/pkg/morse"
+	"strconv"
+	"sync"
 	"time"
 )
 
-func Ind(w http.ResponseWriter, r *http.Request) {
+// Config holds the handler settings taken from the service configuration.
+type Config struct {
+	MaxUploadSize int64
+	StaticDir     string
+	History       history.Store
+	Metrics       *Metrics
+	BatchLimits   archive.Limits
+	// MaxKeyerSessions caps the open keyer sessions; 0 means no cap.
+	MaxKeyerSessions int
+}
+
+type Handlers struct {
+	cfg Config
+
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	// keyerSlots holds a token per open keyer session, if they are capped.
+	keyerSlots chan struct{}
+}
+
+func New(cfg Config) *Handlers {
+	h := &Handlers{cfg: cfg, closing: make(chan struct{})}
+	if cfg.MaxKeyerSessions > 0 {
+		h.keyerSlots = make(chan struct{}, cfg.MaxKeyerSessions)
+	}
+
+	return h
+}
+
+// Shutdown ends the keyer sessions, which the HTTP server does not track
+// once their connections are taken over.
+func (h *Handlers) Shutdown() {
+	h.closeOnce.Do(func() { close(h.closing) })
+}
+
+// maxFormMemory is the part of a multipart form kept in memory; larger
+// files are spilled to temporary files.
+const maxFormMemory = 10 << 20
+
+// parseForm limits the request body to MaxUploadSize and parses the form.
+// It writes the error response itself and reports whether to go on.
+func (h *Handlers) parseForm(w http.ResponseWriter, r *http.Request, allowURLEncoded bool) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadSize)
+
+	err := r.ParseMultipartForm(maxFormMemory)
+	if err == nil || allowURLEncoded && errors.Is(err, http.ErrNotMultipart) {
+		return true
+	}
+	formError(w, err)
+
+	return false
+}
+
+// formError writes the response to a request whose form cannot be read.
+func formError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	http.Error(w, fmt.Sprintf("parse form error: %v", err), status)
+}
+
+func (h *Handlers) Ind(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		_, _ = w.Write([]byte("method not allowed"))
@@ -18,18 +92,48 @@ func Ind(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.ServeFile(w, r, "index.html")
+	http.ServeFile(w, r, filepath.Join(h.cfg.StaticDir, "index.html"))
 }
 
-func Upload(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) Upload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		_, _ = w.Write([]byte("method not allowed"))
 		return
 	}
 
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, fmt.Sprintf("parse form error: %v", err), http.StatusInternalServerError)
+	if !h.parseForm(w, r, false) {
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	mediaType := negotiate(r.Header.Get("Accept"), mediaText, mediaJSON, mediaWAV)
+	if format := r.FormValue("format"); format != "" {
+		var ok bool
+		if mediaType, ok = uploadFormats[format]; !ok {
+			http.Error(w, fmt.Sprintf("unknown format %q", format), http.StatusBadRequest)
+			return
+		}
+	}
+	if mediaType == "" {
+		http.Error(w, "not acceptable: the result is available as text/plain, application/json or audio/wav",
+			http.StatusNotAcceptable)
+		return
+	}
+
+	var audioOpts service.AudioOptions
+	if mediaType == mediaWAV {
+		var err error
+		if audioOpts, err = audioOptions(r); err != nil {
+			http.Error(w, fmt.Sprintf("audio options error: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	conv, alphabet, err := uploadConverter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -40,27 +144,430 @@ func Upload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	src, ok := decodeUpload(w, r, file)
+	if !ok {
+		return
+	}
+
+	// The result is spooled to a temporary file, so that the warnings are
+	// known before the response headers are sent; only the head of either
+	// side goes to the history.
+	out, err := os.CreateTemp("", "morse-upload-*")
 	if err != nil {
-		http.Error(w, fmt.Sprintf("read file error: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("create result file error: %v", err), http.StatusInternalServerError)
 		return
 	}
+	defer func() {
+		_ = out.Close()
+		_ = os.Remove(out.Name())
+	}()
 
-	converted, err := service.ConvertAuto(string(data))
+	var input, output historyText
+	detection, report, err := service.ConvertStream(io.MultiWriter(out, &output), io.TeeReader(src, &input),
+		service.Direction(r.FormValue("direction")), conv)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("convert error: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("convert error: %v", err), convertStatus(err))
+		return
+	}
+	direction := detection.Direction
+	cs := src.Charset()
+
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, fmt.Sprintf("read result file error: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	ts := time.Now().UTC().Format("2006-01-02T15-04-05Z")
-	ext := filepath.Ext(header.Filename)
-	outName := fmt.Sprintf("%s%s", ts, ext)
+	id := h.record(r, history.Entry{
+		Direction: string(direction),
+		Alphabet:  alphabet,
+		Filename:  header.Filename,
+	}, &input, &output, report)
+
+	setWarnings(w.Header(), report)
+	if id != "" {
+		w.Header().Set("X-History-ID", id)
+	}
+	w.Header().Set("X-Input-Charset", cs)
+	if formBool(r, "download") {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": resultFilename(header.Filename, direction, mediaType),
+		}))
+	}
+
+	switch mediaType {
+	case mediaJSON:
+		result, err := io.ReadAll(out)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("read result file error: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-	if err := os.WriteFile(outName, []byte(converted), 0o644); err != nil {
-		http.Error(w, fmt.Sprintf("write result file error: %v", err), http.StatusInternalServerError)
+		writeJSON(w, http.StatusOK, ConvertResponse{
+			Result:       string(result),
+			Direction:    direction,
+			Confidence:   detection.Confidence,
+			Notation:     detection.Notation.Name(),
+			Alphabet:     alphabet,
+			Warnings:     warnings(report),
+			WarningCount: report.Total,
+			Corrections:  corrections(report),
+			Charset:      cs,
+			HistoryID:    id,
+		})
+	case mediaWAV:
+		// The audio is keyed from the Morse side of the conversion, which
+		// for decoding is the uploaded file.
+		var code []byte
+		if direction == service.DirectionDecode {
+			code, err = reread(file, cs)
+		} else {
+			code, err = io.ReadAll(out)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("read file error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		a, err := service.NewAudio(string(code), conv, audioOpts)
+		if err != nil {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, fmt.Sprintf("audio error: %v", err), audioStatus(err))
+			return
+		}
+
+		writeAudio(w, a)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.FormatInt(output.n, 10))
+		_, _ = io.Copy(w, out)
+	}
+}
+
+// reread reads an uploaded file again from the start, decoded from cs.
+func reread(file io.ReadSeeker, cs string) ([]byte, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	src, err := charset.NewReader(file, cs)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(src)
+}
+
+// uploadConverter returns the converter an upload form asks for and the
+// name of its alphabet.
+func uploadConverter(r *http.Request) (morse.Converter, string, error) {
+	alphabet := r.FormValue("alphabet")
+	options, err := notationOptions(r.FormValue("notation"))
+	if err != nil {
+		return morse.Converter{}, "", fmt.Errorf("notation error: %w", err)
+	}
+	if formBool(r, "correct") {
+		options = append(options, correction)
+	}
+
+	conv, err := service.Converter(alphabet, options...)
+	if err != nil {
+		return morse.Converter{}, "", fmt.Errorf("alphabet error: %w", err)
+	}
+	if alphabet == "" {
+		alphabet = morse.DefaultAlphabet
+	}
+
+	return conv, alphabet, nil
+}
+
+// decodeUpload transcodes an uploaded file to UTF-8 from the charset form
+// field or, without one, from the charset detected. It writes the error
+// response itself and reports whether to go on. Reading what is not text
+// fails with charset.ErrNotText.
+func decodeUpload(w http.ResponseWriter, r *http.Request, file io.Reader) (*charset.Reader, bool) {
+	src, err := charset.NewReader(file, r.FormValue("charset"))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		formError(w, err)
+		return nil, false
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, charset.ErrUnknown) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, fmt.Sprintf("charset error: %v", err), status)
+		return nil, false
+	}
+
+	return src, true
+}
+
+// record saves a completed upload to the history and counts it. It
+// returns the ID of the entry, or "" if the history failed: that is only
+// logged, as the conversion itself succeeded.
+func (h *Handlers) record(r *http.Request, e history.Entry, input, output *historyText, report morse.ConversionReport) string {
+	e.Input, e.Output = input.String(), output.String()
+	e.Warnings = report.Total
+
+	direction := service.Direction(e.Direction)
+	h.cfg.Metrics.conversion(direction, e.Alphabet, int(input.n), int(output.n), report)
+
+	entry, err := h.cfg.History.Save(r.Context(), e)
+	if err != nil {
+		middleware.Logger(r.Context()).Error("save history", "error", err)
+	}
+
+	middleware.Logger(r.Context()).Debug("converted",
+		"history_id", entry.ID,
+		"direction", direction,
+		"alphabet", e.Alphabet,
+		"input_bytes", input.n,
+		"output_bytes", output.n,
+		"warnings", report.Total)
+
+	return entry.ID
+}
+
+// convertStatus maps a conversion error to the HTTP status of its response.
+func convertStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrEmptyInput), errors.Is(err, service.ErrInvalidDirection):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrAmbiguousInput):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, charset.ErrNotText):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// correction is how requests that ask for it correct unknown codes: one
+// edit away from a known code, or a run of codes with the separators lost.
+var correction = morse.WithCorrection(1, true)
+
+// notationOptions returns the option for a notation named in a request; an
+// empty name keeps the default.
+func notationOptions(name string) ([]morse.ConverterOption, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	n, ok := morse.LookupNotation(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown notation %q", name)
+	}
+
+	return []morse.ConverterOption{morse.WithNotation(n)}, nil
+}
+
+// formBool reports whether a form field is set, as by a checkbox.
+func formBool(r *http.Request, name string) bool {
+	v := r.FormValue(name)
+	if v == "on" {
+		return true
+	}
+
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// maxWarningHeaders limits the X-Conversion-Warning and
+// X-Conversion-Correction headers of a response; X-Conversion-Warnings and
+// X-Conversion-Corrections always hold the total counts.
+const maxWarningHeaders = 20
+
+func setWarnings(h http.Header, report morse.ConversionReport) {
+	h.Set("X-Conversion-Warnings", strconv.Itoa(report.Total))
+
+	for i, issue := range report.Issues {
+		if i == maxWarningHeaders {
+			break
+		}
+		h.Add("X-Conversion-Warning", fmt.Sprintf("%v offset=%d text=%s",
+			issue.Position, issue.Offset, strconv.QuoteToASCII(issue.Err.Text)))
+	}
+
+	if report.Corrected == 0 {
+		return
+	}
+	h.Set("X-Conversion-Corrections", strconv.Itoa(report.Corrected))
+	for i, c := range report.Corrections {
+		if i == maxWarningHeaders {
+			break
+		}
+		h.Add("X-Conversion-Correction", fmt.Sprintf("%v offset=%d code=%s text=%s",
+			c.Position, c.Offset, strconv.QuoteToASCII(c.Code), strconv.QuoteToASCII(c.Text)))
+	}
+}
+
+func (h *Handlers) Audio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = w.Write([]byte("method not allowed"))
+		return
+	}
+
+	if !h.parseForm(w, r, true) {
+		return
+	}
+
+	conv, err := service.Converter(r.FormValue("alphabet"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("alphabet error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := audioOptions(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("audio options error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	text := r.FormValue("text")
+	if text == "" {
+		file, _, err := r.FormFile("myFile")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("read form file error: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		src, ok := decodeUpload(w, r, file)
+		if !ok {
+			return
+		}
+
+		data, err := io.ReadAll(src)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, charset.ErrNotText) {
+				status = http.StatusUnsupportedMediaType
+			}
+			http.Error(w, fmt.Sprintf("read file error: %v", err), status)
+			return
+		}
+		text = string(data)
+	}
+
+	a, err := service.NewAudio(text, conv, opts)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("audio error: %v", err), audioStatus(err))
+		return
+	}
+
+	writeAudio(w, a)
+}
+
+// writeAudio streams a WAV file as the response; its size is known before
+// the first sample is rendered.
+func writeAudio(w http.ResponseWriter, a *service.Audio) {
+	w.Header().Set("Content-Type", mediaWAV)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size(), 10))
+	_ = a.Render(w)
+}
+
+func audioStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAudioTooLong):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrEmptyInput),
+		errors.Is(err, morse.ErrInvalidTiming),
+		errors.Is(err, audio.ErrInvalidTone):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *Handlers) DecodeAudio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = w.Write([]byte("method not allowed"))
+		return
+	}
+
+	if !h.parseForm(w, r, false) {
+		return
+	}
+
+	conv, err := service.Converter(r.FormValue("alphabet"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("alphabet error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var freq float64
+	if v := r.FormValue("freq"); v != "" {
+		if freq, err = strconv.ParseFloat(v, 64); err != nil {
+			http.Error(w, fmt.Sprintf("freq error: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	file, _, err := r.FormFile("myFile")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("read form file error: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	text, wpm, err := service.DecodeAudio(file, conv, freq)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, audio.ErrUnsupportedWAV) || errors.Is(err, service.ErrNoSignal) {
+			status = http.StatusUnprocessableEntity
+		}
+		http.Error(w, fmt.Sprintf("decode audio error: %v", err), status)
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	_, _ = w.Write([]byte(converted))
+	w.Header().Set("X-Morse-WPM", strconv.FormatFloat(wpm, 'f', 1, 64))
+	_, _ = w.Write([]byte(text))
+}
+
+func audioOptions(r *http.Request) (service.AudioOptions, error) {
+	opts := service.DefaultAudioOptions
+
+	fields := []struct {
+		name string
+		dst  *float64
+	}{
+		{"wpm", &opts.WPM},
+		{"farnsworth", &opts.Farnsworth},
+		{"freq", &opts.Tone.Frequency},
+		{"volume", &opts.Tone.Volume},
+	}
+	for _, f := range fields {
+		v := r.FormValue(f.name)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return opts, fmt.Errorf("%s: %w", f.name, err)
+		}
+		*f.dst = parsed
+	}
+
+	if v := r.FormValue("rate"); v != "" {
+		rate, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("rate: %w", err)
+		}
+		opts.Tone.SampleRate = rate
+	}
+
+	if v := r.FormValue("rise"); v != "" {
+		ms, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return opts, fmt.Errorf("rise: %w", err)
+		}
+		opts.Tone.Rise = time.Duration(ms * float64(time.Millisecond))
+	}
+
+	return opts, nil
 }
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/internal/service"
+	"sprint6/pkg/audio"
+	"sprint6/pkg/morse"
+)
+
+func uploadRequest(t *testing.T, name, content string, fields map[string]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		require.NoError(t, mw.WriteField(k, v))
+	}
+	fw, err := mw.CreateFormFile("myFile", name)
+	require.NoError(t, err)
+	_, err = fw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return req
+}
+
+func TestUploadNegotiation(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		accept      string
+		fields      map[string]string
+		status      int
+		contentType string
+		disposition string
+	}{
+		{
+			name:        "default text",
+			content:     "СОС",
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:        "browser accept",
+			content:     "СОС",
+			accept:      "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:        "json",
+			content:     "СОС",
+			accept:      "application/json",
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "wav preferred by quality",
+			content:     "... --- ...",
+			accept:      "text/plain;q=0.5, audio/*",
+			status:      http.StatusOK,
+			contentType: "audio/wav",
+		},
+		{
+			name:        "not acceptable",
+			content:     "СОС",
+			accept:      "image/png, text/plain;q=0",
+			status:      http.StatusNotAcceptable,
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:        "format field wins over accept",
+			content:     "СОС",
+			accept:      "image/png",
+			fields:      map[string]string{"format": "json"},
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "unknown format",
+			content:     "СОС",
+			fields:      map[string]string{"format": "pdf"},
+			status:      http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:        "download encoded",
+			content:     "СОС",
+			fields:      map[string]string{"download": "on"},
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			disposition: `attachment; filename=letter.morse.txt`,
+		},
+		{
+			name:        "download decoded json",
+			content:     "... --- ...",
+			fields:      map[string]string{"download": "true", "format": "json"},
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			disposition: `attachment; filename=letter.text.json`,
+		},
+		{
+			name:        "download wav",
+			content:     "СОС",
+			fields:      map[string]string{"download": "1", "format": "wav", "wpm": "30"},
+			status:      http.StatusOK,
+			contentType: "audio/wav",
+			disposition: `attachment; filename=letter.wav`,
+		},
+		{
+			name:        "bad audio options",
+			content:     "СОС",
+			fields:      map[string]string{"format": "wav", "wpm": "fast"},
+			status:      http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(testConfig(t))
+
+			req := uploadRequest(t, "letter.txt", tt.content, tt.fields)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			h.Upload(rec, req)
+
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, tt.disposition, rec.Header().Get("Content-Disposition"))
+			assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			switch tt.contentType {
+			case "application/json; charset=utf-8":
+				var resp ConvertResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+				assert.Equal(t, rec.Header().Get("X-History-ID"), resp.HistoryID)
+				assert.NotEmpty(t, resp.Result)
+			case "audio/wav":
+				assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get("Content-Length"))
+				f, samples, err := audio.ReadWAV(rec.Body)
+				require.NoError(t, err)
+				assert.Equal(t, audio.DefaultTone.SampleRate, f.SampleRate)
+				assert.NotEmpty(t, samples)
+			}
+		})
+	}
+}
+
+func TestUploadCorrection(t *testing.T) {
+	h := New(testConfig(t))
+
+	rec := httptest.NewRecorder()
+	h.Upload(rec, uploadRequest(t, "code.txt", "....---.-. -------", map[string]string{"correct": "on"}))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "ХОРОШ", rec.Body.String())
+	assert.Equal(t, "0", rec.Header().Get("X-Conversion-Warnings"))
+	assert.Equal(t, "2", rec.Header().Get("X-Conversion-Corrections"))
+	assert.Equal(t, []string{
+		`1:1 offset=0 code="....---.-." text="\u0425\u041e\u0420"`,
+		`1:12 offset=11 code="-------" text="\u041e\u0428"`,
+	}, rec.Header().Values("X-Conversion-Correction"))
+
+	rec = httptest.NewRecorder()
+	h.Upload(rec, uploadRequest(t, "code.txt", "....---.-. -------", map[string]string{"direction": "decode"}))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, "2", rec.Header().Get("X-Conversion-Warnings"))
+	assert.Empty(t, rec.Header().Get("X-Conversion-Corrections"))
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{mediaText, mediaJSON, mediaWAV}
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", mediaText},
+		{"*/*", mediaText},
+		{"application/json", mediaJSON},
+		{"application/json;q=0.9, text/plain;q=0.8", mediaJSON},
+		{"audio/*, text/*;q=0.1", mediaWAV},
+		{"text/*;q=0.5, text/plain;q=0", ""},
+		{"image/png", ""},
+		{"text/plain;q=abc", ""},
+		{"APPLICATION/JSON", mediaJSON},
+		{"audio/x-wav", mediaWAV},
+		{"audio/wave, text/plain;q=0.5", mediaWAV},
+		{"audio/vnd.wave", mediaWAV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiate(tt.accept, offers...))
+		})
+	}
+}
+
+func TestResultFilename(t *testing.T) {
+	tests := []struct {
+		original  string
+		direction string
+		mediaType string
+		want      string
+	}{
+		{"letter.txt", "encode", mediaText, "letter.morse.txt"},
+		{"letter.txt", "decode", mediaText, "letter.text.txt"},
+		{"code.morse.txt", "decode", mediaJSON, "code.morse.text.json"},
+		{"письмо.txt", "encode", mediaWAV, "письмо.wav"},
+		{`C:\Users\me\notes.txt`, "encode", mediaText, "notes.morse.txt"},
+		{"../../etc/passwd", "encode", mediaText, "passwd.morse.txt"},
+		{"", "encode", mediaText, "result.morse.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.original, func(t *testing.T) {
+			assert.Equal(t, tt.want, resultFilename(tt.original, service.Direction(tt.direction), tt.mediaType))
+		})
+	}
+}
+
+func TestUploadCharset(t *testing.T) {
+	h := New(testConfig(t))
+
+	tests := []struct {
+		name    string
+		content string
+		fields  map[string]string
+		status  int
+		body    string
+		charset string
+	}{
+		{"utf-8", "СОС", nil, http.StatusOK, "... --- ...", "utf-8"},
+		{"utf-8 bom", "\xef\xbb\xbfСОС", nil, http.StatusOK, "... --- ...", "utf-8"},
+		{"windows-1251", "\xd1\xce\xd1 \xcc\xc8\xd0", nil, http.StatusOK, "... --- ...   -- .. .-.", "windows-1251"},
+		{"koi8-r", "\xf3\xef\xf3 \xed\xe9\xf2", nil, http.StatusOK, "... --- ...   -- .. .-.", "koi8-r"},
+		{"utf-16le", "\xff\xfe\x21\x04\x1e\x04\x21\x04", nil, http.StatusOK, "... --- ...", "utf-16le"},
+		{"override", "\xd1\xce\xd1", map[string]string{"charset": "cp1251"}, http.StatusOK, "... --- ...", "windows-1251"},
+		{"unknown charset", "СОС", map[string]string{"charset": "ebcdic"}, http.StatusBadRequest, "charset error", ""},
+		// Detect only sees the ASCII start of the file.
+		{"windows-1251 after ascii", strings.Repeat("12345 ", 1000) + "\xd1\xce\xd1", map[string]string{"direction": "encode"},
+			http.StatusOK, "... --- ...", "windows-1251"},
+		{"binary", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", nil, http.StatusUnsupportedMediaType, "not a text file", ""},
+		{"invalid utf-8", strings.Repeat("СОС ", 2000) + "\xd1\xce", nil, http.StatusUnsupportedMediaType, "not a text file", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Upload(rec, uploadRequest(t, "letter.txt", tt.content, tt.fields))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.body)
+			assert.Equal(t, tt.charset, rec.Header().Get("X-Input-Charset"))
+		})
+	}
+}
+
+func TestAudio(t *testing.T) {
+	h := New(testConfig(t))
+
+	form := func(fields map[string]string) *http.Request {
+		values := url.Values{}
+		for k, v := range fields {
+			values.Set(k, v)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/audio", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"text", form(map[string]string{"text": "СОС", "wpm": "25", "freq": "700", "rate": "16000"}), http.StatusOK},
+		{"file", uploadRequest(t, "sos.txt", "... --- ...", nil), http.StatusOK},
+		{"no input", form(nil), http.StatusBadRequest},
+		{"bad wpm", form(map[string]string{"text": "СОС", "wpm": "fast"}), http.StatusBadRequest},
+		{"zero wpm", form(map[string]string{"text": "СОС", "wpm": "0"}), http.StatusBadRequest},
+		{"bad freq", form(map[string]string{"text": "СОС", "freq": "high"}), http.StatusBadRequest},
+		{"freq above nyquist", form(map[string]string{"text": "СОС", "freq": "5000"}), http.StatusBadRequest},
+		{"bad rate", form(map[string]string{"text": "СОС", "rate": "8k"}), http.StatusBadRequest},
+		{"high rate", form(map[string]string{"text": "СОС", "rate": "96000"}), http.StatusBadRequest},
+		// 60 words of 12 s each.
+		{"too long", form(map[string]string{"text": strings.Repeat("ПАРИС ", 60), "wpm": "5"}), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Audio(rec, tt.req)
+
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+			if tt.status != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "audio/wav", rec.Header().Get("Content-Type"))
+			assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get("Content-Length"))
+
+			_, samples, err := audio.ReadWAV(rec.Body)
+			require.NoError(t, err)
+			assert.NotEmpty(t, samples)
+		})
+	}
+}
+
+func TestDecodeAudio(t *testing.T) {
+	h := New(testConfig(t))
+
+	rec := httptest.NewRecorder()
+	h.Audio(rec, uploadRequest(t, "sos.txt", "СОС", map[string]string{"wpm": "20"}))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var silence bytes.Buffer
+	require.NoError(t, audio.DefaultTone.WriteWAV(&silence, []morse.Element{{Dur: time.Second}}))
+
+	tests := []struct {
+		name   string
+		file   string
+		status int
+		body   string
+		wpm    string
+	}{
+		{"round trip", rec.Body.String(), http.StatusOK, "СОС", "20.0"},
+		{"no signal", silence.String(), http.StatusUnprocessableEntity, "decode audio error", ""},
+		{"not wav", "СОС", http.StatusUnprocessableEntity, "decode audio error", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.DecodeAudio(rec, uploadRequest(t, "in.wav", tt.file, nil))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.body)
+			assert.Equal(t, tt.wpm, rec.Header().Get("X-Morse-WPM"))
+		})
+	}
+
+	t.Run("no file", func(t *testing.T) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		require.NoError(t, mw.WriteField("freq", "600"))
+		require.NoError(t, mw.Close())
+		req := httptest.NewRequest(http.MethodPost, "/decode-audio", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		rec := httptest.NewRecorder()
+		h.DecodeAudio(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"sprint6/internal/history"
+	"sprint6/internal/middleware"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+
+	// maxHistoryText is how much of the input and of the output of a
+	// conversion the history keeps.
+	maxHistoryText = 64 << 10
+)
+
+type HistorySummary struct {
+	ID           string    `json:"id"`
+	Created      time.Time `json:"created"`
+	Direction    string    `json:"direction"`
+	Alphabet     string    `json:"alphabet"`
+	Filename     string    `json:"filename"`
+	WarningCount int       `json:"warningCount"`
+}
+
+type HistoryDetail struct {
+	HistorySummary
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+type HistoryList struct {
+	Entries []HistorySummary `json:"entries"`
+}
+
+// History lists the latest conversions, newest first. The limit query
+// parameter sets how many, up to maxHistoryLimit.
+func (h *Handlers) History(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxHistoryLimit {
+			writeJSON(w, http.StatusBadRequest, errorResponse{
+				Error: fmt.Sprintf("limit must be between 1 and %d, got %q", maxHistoryLimit, v),
+			})
+			return
+		}
+		limit = n
+	}
+
+	entries, err := h.cfg.History.List(r.Context(), limit)
+	if err != nil {
+		middleware.Logger(r.Context()).Error("list history", "error", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: fmt.Sprintf("list history error: %v", err)})
+		return
+	}
+
+	resp := HistoryList{Entries: make([]HistorySummary, 0, len(entries))}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, summary(e))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HistoryEntry returns a single conversion with its input and output, of
+// which the history keeps the first maxHistoryText bytes.
+func (h *Handlers) HistoryEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	e, err := h.cfg.History.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, history.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		middleware.Logger(r.Context()).Error("read history", "error", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: fmt.Sprintf("read history error: %v", err)})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, HistoryDetail{
+		HistorySummary: summary(e),
+		Input:          e.Input,
+		Output:         e.Output,
+	})
+}
+
+func summary(e history.Entry) HistorySummary {
+	return HistorySummary{
+		ID:           e.ID,
+		Created:      e.Created,
+		Direction:    e.Direction,
+		Alphabet:     e.Alphabet,
+		Filename:     e.Filename,
+		WarningCount: e.Warnings,
+	}
+}
+
+// historyText keeps the head of what is written to it for the history, up
+// to maxHistoryText bytes, and counts all of it.
+type historyText struct {
+	head []byte
+	n    int64
+}
+
+func (t *historyText) Write(p []byte) (int, error) {
+	t.n += int64(len(p))
+	if room := maxHistoryText - len(t.head); room > 0 {
+		t.head = append(t.head, p[:min(room, len(p))]...)
+	}
+
+	return len(p), nil
+}
+
+// String returns the head, without a rune cut short at its end.
+func (t *historyText) String() string {
+	head := t.head
+	if t.n > int64(len(head)) {
+		for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(head[i]) {
+				if !utf8.FullRune(head[i:]) {
+					head = head[:i]
+				}
+				break
+			}
+		}
+	}
+
+	return string(head)
+}
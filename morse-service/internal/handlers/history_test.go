@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/internal/history"
+)
+
+func upload(t *testing.T, h *Handlers, name, content string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.Upload(rec, uploadRequest(t, name, content, nil))
+
+	return rec
+}
+
+func TestHistory(t *testing.T) {
+	h := New(testConfig(t))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/history", h.History)
+	mux.HandleFunc("/history/{id}", h.HistoryEntry)
+
+	get := func(url string, v any) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if v != nil && rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+		}
+		return rec.Code
+	}
+
+	var list HistoryList
+	require.Equal(t, http.StatusOK, get("/history", &list))
+	assert.Empty(t, list.Entries)
+
+	first := upload(t, h, "sos.txt", "СОСQ")
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "... --- ...", first.Body.String())
+	firstID := first.Header().Get("X-History-ID")
+	require.NotEmpty(t, firstID)
+
+	second := upload(t, h, "code.txt", "... --- ...")
+	require.Equal(t, http.StatusOK, second.Code)
+	secondID := second.Header().Get("X-History-ID")
+	assert.NotEqual(t, firstID, secondID)
+
+	require.Equal(t, http.StatusOK, get("/history", &list))
+	require.Len(t, list.Entries, 2)
+	assert.Equal(t, secondID, list.Entries[0].ID)
+	assert.Equal(t, "decode", list.Entries[0].Direction)
+	assert.Equal(t, firstID, list.Entries[1].ID)
+	assert.Equal(t, "sos.txt", list.Entries[1].Filename)
+	assert.Equal(t, 1, list.Entries[1].WarningCount)
+
+	require.Equal(t, http.StatusOK, get("/history?limit=1", &list))
+	assert.Len(t, list.Entries, 1)
+
+	var detail HistoryDetail
+	require.Equal(t, http.StatusOK, get("/history/"+firstID, &detail))
+	assert.Equal(t, HistoryDetail{
+		HistorySummary: list.Entries[0],
+		Input:          "СОСQ",
+		Output:         "... --- ...",
+	}.Input, detail.Input)
+	assert.Equal(t, "encode", detail.Direction)
+	assert.Equal(t, "russian", detail.Alphabet)
+	assert.Equal(t, "... --- ...", detail.Output)
+
+	assert.Equal(t, http.StatusNotFound, get("/history/0123456789abcdef0123", nil))
+	assert.Equal(t, http.StatusBadRequest, get("/history?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, get("/history?limit=many", nil))
+}
+
+// brokenStore fails to save anything.
+type brokenStore struct {
+	history.Store
+}
+
+func (brokenStore) Save(context.Context, history.Entry) (history.Entry, error) {
+	return history.Entry{}, errors.New("disk full")
+}
+
+func TestHistoryFailureKeepsResult(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.History = brokenStore{cfg.History}
+	h := New(cfg)
+
+	rec := upload(t, h, "sos.txt", "СОС")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "... --- ...", rec.Body.String())
+	assert.Empty(t, rec.Header().Get("X-History-ID"))
+}
+
+func TestHistoryText(t *testing.T) {
+	var short historyText
+	_, _ = short.Write([]byte("СОС"))
+	assert.Equal(t, "СОС", short.String())
+
+	// "Ж" is two bytes, so the head ends in half a rune.
+	var long historyText
+	_, _ = long.Write([]byte(strings.Repeat("a", maxHistoryText-1)))
+	_, _ = long.Write([]byte("ЖЖ"))
+	assert.Equal(t, strings.Repeat("a", maxHistoryText-1), long.String())
+	assert.EqualValues(t, maxHistoryText+3, long.n)
+}
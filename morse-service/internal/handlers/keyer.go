@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"sprint6/internal/middleware"
+	"sprint6/internal/service"
+	"sprint6/internal/websocket"
+	"sprint6/pkg/morse"
+)
+
+const (
+	// defaultKeyerWPM is the speed a keyer expects until the sender has
+	// keyed both dots and dashes.
+	defaultKeyerWPM = 20
+
+	// keyerIdleTimeout closes keyer sessions the browser left open.
+	keyerIdleTimeout = 5 * time.Minute
+
+	// keyerRetryAfter is how long a client turned away by the session cap
+	// is asked to wait.
+	keyerRetryAfter = 30 * time.Second
+
+	maxKeyEventSize = 1 << 10
+)
+
+// KeyEvent is a key press or release sent to /ws/keyer. Time is in
+// milliseconds on any clock of the client, e.g. performance.now().
+type KeyEvent struct {
+	Key  string  `json:"key"`
+	Time float64 `json:"time"`
+}
+
+// KeyerMessage is sent back after every event and whenever a pause
+// completes a character or word. Text is the newly decoded text, Pending
+// the dots and dashes of the character being keyed.
+type KeyerMessage struct {
+	Text    string  `json:"text"`
+	Pending string  `json:"pending"`
+	WPM     float64 `json:"wpm"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Keyer decodes a key worked in the browser as it is worked. The alphabet
+// and the initial speed come from the query.
+func (h *Handlers) Keyer(w http.ResponseWriter, r *http.Request) {
+	conv, err := service.Converter(r.FormValue("alphabet"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("alphabet error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	wpm := float64(defaultKeyerWPM)
+	if v := r.FormValue("wpm"); v != "" {
+		if wpm, err = strconv.ParseFloat(v, 64); err != nil {
+			http.Error(w, fmt.Sprintf("wpm error: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	k, err := conv.NewKeyer(wpm)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("wpm error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if h.keyerSlots != nil {
+		select {
+		case h.keyerSlots <- struct{}{}:
+			defer func() { <-h.keyerSlots }()
+		default:
+			w.Header().Set("Retry-After", strconv.Itoa(int(keyerRetryAfter.Seconds())))
+			http.Error(w, "too many keyer sessions", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	c, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	c.MaxMessageSize = maxKeyEventSize
+
+	s := &keyerSession{conn: c, keyer: k}
+	err = s.run(h.closing)
+	_ = c.Close(websocket.CloseNormal, "")
+	middleware.Logger(r.Context()).Debug("keyer closed", "decoded", s.decoded, "wpm", k.WPM(), "reason", err)
+}
+
+// keyerSession holds the state of a key between events.
+type keyerSession struct {
+	conn    *websocket.Conn
+	keyer   *morse.Keyer
+	down    bool
+	started bool
+	last    float64   // time of the last event, on the client clock
+	upAt    time.Time // when the key was released, on the server clock
+	decoded int
+}
+
+// run serves the session until the client leaves or closing is closed.
+func (s *keyerSession) run(closing <-chan struct{}) error {
+	type read struct {
+		typ websocket.MessageType
+		msg []byte
+		err error
+	}
+
+	reads := make(chan read)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			_ = s.conn.SetReadDeadline(time.Now().Add(keyerIdleTimeout))
+			typ, msg, err := s.conn.ReadMessage()
+			select {
+			case reads <- read{typ, msg, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var wake <-chan time.Time
+
+	for {
+		select {
+		case <-closing:
+			return s.conn.Close(websocket.CloseGoingAway, "server shutting down")
+
+		case <-wake:
+			if text := s.keyer.Idle(time.Since(s.upAt)); text != "" {
+				if err := s.send(KeyerMessage{Text: text}); err != nil {
+					return err
+				}
+			}
+			wake = s.wake()
+
+		case rd := <-reads:
+			if rd.err != nil {
+				var closed websocket.CloseError
+				if errors.As(rd.err, &closed) {
+					return nil
+				}
+				if errors.Is(rd.err, os.ErrDeadlineExceeded) {
+					_ = s.conn.Close(websocket.CloseGoingAway, "idle")
+				}
+				return rd.err
+			}
+			if rd.typ != websocket.TextMessage {
+				return s.conn.Close(websocket.CloseUnsupportedData, "key events are JSON text")
+			}
+
+			var e KeyEvent
+			if err := json.Unmarshal(rd.msg, &e); err != nil {
+				if err := s.send(KeyerMessage{Error: fmt.Sprintf("decode event error: %v", err)}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			text, err := s.event(e)
+			msg := KeyerMessage{Text: text}
+			if err != nil {
+				msg.Error = err.Error()
+			}
+			if err := s.send(msg); err != nil {
+				return err
+			}
+
+			wake = s.wake()
+		}
+	}
+}
+
+// event passes the period a key event ends to the keyer.
+func (s *keyerSession) event(e KeyEvent) (string, error) {
+	var down bool
+	switch e.Key {
+	case "down":
+		down = true
+	case "up":
+	default:
+		return "", fmt.Errorf("unknown key %q, want down or up", e.Key)
+	}
+
+	text := ""
+	switch {
+	case !s.started && !down:
+		return "", errors.New("key released before it was pressed")
+	case !s.started:
+	case down == s.down:
+		return "", fmt.Errorf("key is already %s", e.Key)
+	case e.Time < s.last:
+		return "", errors.New("event is older than the previous one")
+	default:
+		d := time.Duration((e.Time - s.last) * float64(time.Millisecond))
+		text = s.keyer.Key(morse.Element{On: s.down, Dur: d})
+	}
+
+	s.started, s.down, s.last = true, down, e.Time
+	if !down {
+		s.upAt = time.Now()
+	}
+
+	return text, nil
+}
+
+// wake returns a channel that fires when the pause since the key was
+// released may complete a character or word, or nil if none can.
+func (s *keyerSession) wake() <-chan time.Time {
+	if s.down || !s.started {
+		return nil
+	}
+
+	wait := s.keyer.Wait()
+	if wait == 0 {
+		return nil
+	}
+
+	return time.After(max(wait-time.Since(s.upAt), 0))
+}
+
+func (s *keyerSession) send(msg KeyerMessage) error {
+	s.decoded += len([]rune(msg.Text))
+	msg.Pending = s.keyer.Pending()
+	msg.WPM = s.keyer.WPM()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/internal/websocket"
+	"sprint6/pkg/morse"
+)
+
+func dialKeyer(t *testing.T, h *Handlers, query string) *websocket.Conn {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(h.Keyer))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/keyer?"+query, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close(websocket.CloseNormal, "") })
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	return c
+}
+
+func sendKey(t *testing.T, c *websocket.Conn, key string, at time.Duration) KeyerMessage {
+	t.Helper()
+
+	data, err := json.Marshal(KeyEvent{Key: key, Time: float64(at) / float64(time.Millisecond)})
+	require.NoError(t, err)
+	require.NoError(t, c.WriteMessage(websocket.TextMessage, data))
+
+	return readKeyer(t, c)
+}
+
+func readKeyer(t *testing.T, c *websocket.Conn) KeyerMessage {
+	t.Helper()
+
+	_, data, err := c.ReadMessage()
+	require.NoError(t, err)
+
+	var msg KeyerMessage
+	require.NoError(t, json.Unmarshal(data, &msg))
+	return msg
+}
+
+func TestKeyer(t *testing.T) {
+	c := dialKeyer(t, New(testConfig(t)), "wpm=60")
+
+	tm, err := morse.NewTiming(60, 0)
+	require.NoError(t, err)
+
+	var (
+		text strings.Builder
+		at   time.Duration
+		msg  KeyerMessage
+	)
+	for i, e := range morse.DefaultConverter.Elements(morse.ToMorse("ПАРИС ТЕСТ"), tm) {
+		if i == 0 {
+			msg = sendKey(t, c, "down", at)
+		}
+		at += e.Dur
+		if e.On {
+			msg = sendKey(t, c, "up", at)
+		} else {
+			msg = sendKey(t, c, "down", at)
+		}
+		require.Empty(t, msg.Error)
+		text.WriteString(msg.Text)
+	}
+	assert.Equal(t, "ПАРИС ТЕС", text.String())
+	assert.Equal(t, "-", msg.Pending)
+
+	// The pause after the last release completes the character, then the
+	// word.
+	for !strings.HasSuffix(text.String(), " ") {
+		msg = readKeyer(t, c)
+		text.WriteString(msg.Text)
+	}
+	assert.Equal(t, "ПАРИС ТЕСТ ", text.String())
+	assert.Empty(t, msg.Pending)
+	assert.InDelta(t, 60, msg.WPM, 6)
+}
+
+func TestKeyerEventErrors(t *testing.T) {
+	c := dialKeyer(t, New(testConfig(t)), "")
+
+	tests := []struct {
+		event string
+		err   string
+	}{
+		{`{"key": "up", "time": 0}`, "released before it was pressed"},
+		{`{"key": "down", "time": 100}`, ""},
+		{`{"key": "down", "time": 200}`, "already down"},
+		{`{"key": "up", "time": 50}`, "older than the previous one"},
+		{`{"key": "left"}`, "unknown key"},
+		{`not json`, "decode event error"},
+		{`{"key": "up", "time": 160}`, ""},
+	}
+	for _, tt := range tests {
+		require.NoError(t, c.WriteMessage(websocket.TextMessage, []byte(tt.event)))
+
+		msg := readKeyer(t, c)
+		if tt.err == "" {
+			assert.Empty(t, msg.Error, tt.event)
+		} else {
+			assert.Contains(t, msg.Error, tt.err, tt.event)
+		}
+	}
+}
+
+func TestKeyerShutdown(t *testing.T) {
+	h := New(testConfig(t))
+	c := dialKeyer(t, h, "")
+
+	h.Shutdown()
+	h.Shutdown()
+
+	_, _, err := c.ReadMessage()
+	var closed websocket.CloseError
+	require.ErrorAs(t, err, &closed)
+	assert.Equal(t, websocket.CloseGoingAway, closed.Code)
+}
+
+func TestKeyerBadRequest(t *testing.T) {
+	h := New(testConfig(t))
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"alphabet=klingon", http.StatusBadRequest},
+		{"wpm=fast", http.StatusBadRequest},
+		{"wpm=0", http.StatusBadRequest},
+		{"", http.StatusUpgradeRequired},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.Keyer(rec, httptest.NewRequest(http.MethodGet, "/ws/keyer?"+tt.query, nil))
+		assert.Equal(t, tt.status, rec.Code, tt.query)
+	}
+}
+
+func TestKeyerSessionCap(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MaxKeyerSessions = 1
+	h := New(cfg)
+	c := dialKeyer(t, h, "")
+
+	rec := httptest.NewRecorder()
+	h.Keyer(rec, httptest.NewRequest(http.MethodGet, "/ws/keyer", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// The open session is not affected.
+	msg := sendKey(t, c, "down", 0)
+	assert.Empty(t, msg.Error)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"strings"
+
+	"sprint6/internal/metrics"
+	"sprint6/internal/service"
+	"sprint6/pkg/morse"
+)
+
+// Metrics are the conversion metrics recorded by Upload and Convert.
+type Metrics struct {
+	conversions *metrics.CounterVec
+	inputBytes  *metrics.CounterVec
+	outputBytes *metrics.CounterVec
+	unknown     *metrics.CounterVec
+}
+
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	return &Metrics{
+		conversions: reg.NewCounterVec("morse_conversions_total",
+			"Completed conversions.", "direction", "alphabet"),
+		inputBytes: reg.NewCounterVec("morse_input_bytes_total",
+			"Bytes of conversion input.", "direction"),
+		outputBytes: reg.NewCounterVec("morse_output_bytes_total",
+			"Bytes of conversion output.", "direction"),
+		unknown: reg.NewCounterVec("morse_unknown_symbols_total",
+			"Runes and codes the converter had no encoding for.", "direction", "alphabet"),
+	}
+}
+
+// conversion records a completed conversion; a nil m records nothing.
+func (m *Metrics) conversion(direction service.Direction, alphabet string, in, out int, report morse.ConversionReport) {
+	if m == nil {
+		return
+	}
+
+	d, a := string(direction), strings.ToLower(alphabet)
+	m.conversions.Inc(d, a)
+	m.inputBytes.Add(float64(in), d)
+	m.outputBytes.Add(float64(out), d)
+	m.unknown.Add(float64(report.Total), d, a)
+}
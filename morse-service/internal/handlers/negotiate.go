@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"mime"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sprint6/internal/service"
+)
+
+const (
+	mediaText = "text/plain"
+	mediaJSON = "application/json"
+	mediaWAV  = "audio/wav"
+)
+
+// mediaAliases are other names clients use for an offer.
+var mediaAliases = map[string][]string{
+	mediaWAV: {"audio/x-wav", "audio/wave", "audio/vnd.wave"},
+}
+
+// uploadFormats maps the format form field to a media type, for clients
+// such as HTML forms that cannot set Accept.
+var uploadFormats = map[string]string{
+	"text": mediaText,
+	"json": mediaJSON,
+	"wav":  mediaWAV,
+}
+
+// negotiate picks the offer the Accept header prefers. Every offer gets the
+// quality of the most specific media range that matches it; ties go to the
+// earlier offer. An empty header accepts the first offer. It returns "" if
+// nothing is acceptable.
+func negotiate(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+func quality(accept, offer string) float64 {
+	offerType, _, _ := strings.Cut(offer, "/")
+
+	q, specificity := 0.0, -1
+	for part := range strings.SplitSeq(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		typ, sub, _ := strings.Cut(mediaType, "/")
+
+		var s int
+		switch {
+		case mediaType == offer || slices.Contains(mediaAliases[offer], mediaType):
+			s = 2
+		case typ == offerType && sub == "*":
+			s = 1
+		case typ == "*" && sub == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+
+		rangeQ := 1.0
+		if v, ok := params["q"]; ok {
+			if rangeQ, err = strconv.ParseFloat(v, 64); err != nil || rangeQ < 0 || rangeQ > 1 {
+				continue
+			}
+		}
+
+		q, specificity = rangeQ, s
+	}
+
+	return q
+}
+
+// resultFilename derives the download name of a result from the uploaded
+// file name, e.g. letter.txt becomes letter.morse.txt when encoded.
+func resultFilename(original string, direction service.Direction, mediaType string) string {
+	base := filepath.Base(strings.ReplaceAll(original, `\`, "/"))
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	if base == "" || base == "." || base == "/" {
+		base = "result"
+	}
+
+	suffix := ".text"
+	if direction == service.DirectionEncode {
+		suffix = ".morse"
+	}
+
+	switch mediaType {
+	case mediaJSON:
+		return base + suffix + ".json"
+	case mediaWAV:
+		return base + ".wav"
+	default:
+		return base + suffix + ".txt"
+	}
+}
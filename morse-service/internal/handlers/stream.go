@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"sprint6/internal/history"
+	"sprint6/internal/middleware"
+	"sprint6/internal/service"
+)
+
+// streamChunkSize is how much of the result a chunk event holds at most;
+// the converter writes a character at a time.
+const streamChunkSize = 16 << 10
+
+// streamWriteTimeout bounds each event instead of the whole response,
+// which lasts as long as the conversion.
+const streamWriteTimeout = 10 * time.Second
+
+// streamReadTimeout likewise bounds each read of the upload instead of the
+// whole request body.
+const streamReadTimeout = 10 * time.Second
+
+// StreamProgress is sent after every chunk: how much of the uploaded file
+// has been read.
+type StreamProgress struct {
+	Read    int64   `json:"read"`
+	Total   int64   `json:"total"`
+	Percent float64 `json:"percent"`
+}
+
+// StreamChunk is the next part of the result.
+type StreamChunk struct {
+	Text string `json:"text"`
+}
+
+// StreamDone ends a successful stream; the result itself came in chunks.
+type StreamDone struct {
+	Direction    service.Direction `json:"direction"`
+	Confidence   float64           `json:"confidence"`
+	Notation     string            `json:"notation"`
+	Alphabet     string            `json:"alphabet"`
+	Warnings     []Warning         `json:"warnings"`
+	WarningCount int               `json:"warningCount"`
+	Corrections  []Correction      `json:"corrections,omitempty"`
+	Charset      string            `json:"charset"`
+	HistoryID    string            `json:"historyId,omitempty"`
+}
+
+// maxStreamField limits the form fields sent along with a streamed upload.
+const maxStreamField = 1 << 10
+
+// UploadStream converts an uploaded file like Upload, but sends the result
+// as Server-Sent Events while the file is converted: "chunk" events with
+// the text, "progress" events, and "done" or "error" at the end. Errors
+// found before the first event get a plain response instead. Closing the
+// request stops the conversion, and nothing goes to the history.
+//
+// The file is converted as it is uploaded and progress is that of the
+// request body, so the form fields must come before the file; any after it
+// are ignored.
+func (h *Handlers) UploadStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = w.Write([]byte("method not allowed"))
+		return
+	}
+
+	// The events go out while the body is still read.
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+
+	body := &progressReader{r: http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadSize), ctx: r.Context(), rc: rc}
+	r.Body = body
+
+	file, ok := streamFile(w, r)
+	if !ok {
+		return
+	}
+
+	conv, alphabet, err := uploadConverter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	total := r.ContentLength
+	progress := func() StreamProgress {
+		return StreamProgress{Read: body.n, Total: total, Percent: percent(body.n, total)}
+	}
+	events := &eventStream{w: w, rc: rc}
+	out := &chunkWriter{events: events, progress: progress}
+
+	src, ok := decodeUpload(w, r, file)
+	if !ok {
+		return
+	}
+
+	var input, output historyText
+	buf := bufio.NewWriterSize(io.MultiWriter(out, &output), streamChunkSize)
+	detection, report, err := service.ConvertStream(buf, io.TeeReader(src, &input),
+		service.Direction(r.FormValue("direction")), conv)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil {
+		err = out.flush()
+	}
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case r.Context().Err() != nil:
+		middleware.Logger(r.Context()).Debug("stream canceled", "read", body.n, "total", total)
+		return
+	case err != nil && !events.started && errors.As(err, &tooLarge):
+		formError(w, err)
+		return
+	case err != nil && !events.started:
+		http.Error(w, fmt.Sprintf("convert error: %v", err), convertStatus(err))
+		return
+	case err != nil:
+		_ = events.send("error", errorResponse{Error: fmt.Sprintf("convert error: %v", err)})
+		return
+	}
+
+	// The rest of the body is the end of the form.
+	_, _ = io.Copy(io.Discard, body)
+	_ = events.send("progress", progress())
+
+	id := h.record(r, history.Entry{
+		Direction: string(detection.Direction),
+		Alphabet:  alphabet,
+		Filename:  file.FileName(),
+	}, &input, &output, report)
+
+	_ = events.send("done", StreamDone{
+		Direction:    detection.Direction,
+		Confidence:   detection.Confidence,
+		Notation:     detection.Notation.Name(),
+		Alphabet:     alphabet,
+		Warnings:     warnings(report),
+		WarningCount: report.Total,
+		Corrections:  corrections(report),
+		Charset:      src.Charset(),
+		HistoryID:    id,
+	})
+}
+
+// streamFile reads the form fields of a streamed upload into r.Form, along
+// with the query, and returns the part of the file once it starts. It
+// writes the error response itself and reports whether to go on.
+func streamFile(w http.ResponseWriter, r *http.Request) (*multipart.Part, bool) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		formError(w, err)
+		return nil, false
+	}
+
+	r.Form = r.URL.Query()
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			http.Error(w, "read form file error: no myFile in the form", http.StatusBadRequest)
+			return nil, false
+		}
+		if err != nil {
+			formError(w, err)
+			return nil, false
+		}
+		if part.FormName() == "myFile" {
+			return part, true
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxStreamField+1))
+		if err != nil {
+			formError(w, err)
+			return nil, false
+		}
+		if len(value) > maxStreamField {
+			http.Error(w, fmt.Sprintf("parse form error: field %q is too long", part.FormName()), http.StatusBadRequest)
+			return nil, false
+		}
+		r.Form.Add(part.FormName(), string(value))
+	}
+}
+
+func percent(n, total int64) float64 {
+	if total <= 0 {
+		return 100
+	}
+
+	return min(100, float64(n)*100/float64(total))
+}
+
+// eventStream writes Server-Sent Events. The headers go out with the
+// first event, so that a conversion failing at once gets a plain error.
+type eventStream struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	started bool
+}
+
+func (s *eventStream) send(event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if !s.started {
+		s.started = true
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		// Proxies like nginx would otherwise hold the events back.
+		s.w.Header().Set("X-Accel-Buffering", "no")
+		s.w.WriteHeader(http.StatusOK)
+	}
+
+	_ = s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+
+	return s.rc.Flush()
+}
+
+// chunkWriter sends what the converter writes as chunk events followed by
+// the progress, keeping back a rune split between writes.
+type chunkWriter struct {
+	events   *eventStream
+	progress func() StreamProgress
+	pending  []byte
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	c.pending = append(c.pending, p...)
+
+	n := len(c.pending)
+	for i := n - 1; i >= 0 && i >= n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(c.pending[i]) {
+			if !utf8.FullRune(c.pending[i:]) {
+				n = i
+			}
+			break
+		}
+	}
+
+	if err := c.send(c.pending[:n]); err != nil {
+		return 0, err
+	}
+	c.pending = append(c.pending[:0], c.pending[n:]...)
+
+	return len(p), nil
+}
+
+// flush sends what is left once the converter is done.
+func (c *chunkWriter) flush() error {
+	err := c.send(c.pending)
+	c.pending = nil
+	return err
+}
+
+func (c *chunkWriter) send(text []byte) error {
+	if len(text) == 0 {
+		return nil
+	}
+
+	if err := c.events.send("chunk", StreamChunk{Text: string(text)}); err != nil {
+		return err
+	}
+
+	return c.events.send("progress", c.progress())
+}
+
+// progressReader counts the bytes read and stops once ctx is done. With
+// rc, it moves the read deadline on before every read, so that a slow
+// upload is not cut off by the server's ReadTimeout.
+type progressReader struct {
+	r   io.ReadCloser
+	ctx context.Context
+	rc  *http.ResponseController
+	n   int64
+}
+
+func (p *progressReader) Close() error {
+	return p.r.Close()
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if p.rc != nil {
+		_ = p.rc.SetReadDeadline(time.Now().Add(streamReadTimeout))
+	}
+
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	return n, err
+}
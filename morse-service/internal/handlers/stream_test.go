@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/internal/service"
+	"sprint6/pkg/morse"
+)
+
+type event struct {
+	name string
+	data string
+}
+
+func readEvents(t *testing.T, body string) []event {
+	t.Helper()
+
+	var (
+		events []event
+		e      event
+	)
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		switch line := sc.Text(); {
+		case line == "":
+			events = append(events, e)
+			e = event{}
+		case strings.HasPrefix(line, "event: "):
+			e.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		default:
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+	require.NoError(t, sc.Err())
+
+	return events
+}
+
+func TestUploadStream(t *testing.T) {
+	cfg := testConfig(t)
+	h := New(cfg)
+
+	input := strings.Repeat("СОС ПРИВЕТ МИР ", 10000)
+	req := uploadRequest(t, "big.txt", input, map[string]string{"direction": "encode"})
+	rec := httptest.NewRecorder()
+	h.UploadStream(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+	var (
+		result  strings.Builder
+		chunks  int
+		percent float64
+		done    StreamDone
+	)
+	events := readEvents(t, rec.Body.String())
+	for i, e := range events {
+		switch e.name {
+		case "chunk":
+			var c StreamChunk
+			require.NoError(t, json.Unmarshal([]byte(e.data), &c))
+			result.WriteString(c.Text)
+			chunks++
+		case "progress":
+			var p StreamProgress
+			require.NoError(t, json.Unmarshal([]byte(e.data), &p))
+			assert.GreaterOrEqual(t, p.Percent, percent)
+			// Progress is that of the whole request body.
+			assert.Equal(t, req.ContentLength, p.Total)
+			percent = p.Percent
+		case "done":
+			require.Equal(t, len(events)-1, i, "done is the last event")
+			require.NoError(t, json.Unmarshal([]byte(e.data), &done))
+		default:
+			t.Fatalf("unexpected event %q: %s", e.name, e.data)
+		}
+	}
+
+	want, err := service.Convert(input, service.DirectionEncode, morse.DefaultConverter)
+	require.NoError(t, err)
+	assert.Equal(t, want.Output, result.String())
+	assert.Greater(t, chunks, 2)
+	assert.InDelta(t, 100, percent, 0.001)
+
+	assert.Equal(t, service.DirectionEncode, done.Direction)
+	assert.Equal(t, "russian", done.Alphabet)
+	assert.Equal(t, "utf-8", done.Charset)
+	require.NotEmpty(t, done.HistoryID)
+
+	entry, err := cfg.History.Get(context.Background(), done.HistoryID)
+	require.NoError(t, err)
+	// The history keeps the head of a large conversion.
+	assert.True(t, strings.HasPrefix(input, entry.Input))
+	assert.Greater(t, len(entry.Input), maxHistoryText-utf8.UTFMax)
+	assert.LessOrEqual(t, len(entry.Input), maxHistoryText)
+	assert.True(t, strings.HasPrefix(want.Output, entry.Output))
+	assert.Len(t, entry.Output, maxHistoryText)
+}
+
+func TestUploadStreamErrors(t *testing.T) {
+	h := New(testConfig(t))
+
+	tests := []struct {
+		name    string
+		content string
+		fields  map[string]string
+		status  int
+	}{
+		{"empty", "  ", nil, http.StatusBadRequest},
+		{"unknown alphabet", "СОС", map[string]string{"alphabet": "klingon"}, http.StatusBadRequest},
+		{"unknown notation", "СОС", map[string]string{"notation": "smoke"}, http.StatusBadRequest},
+		{"bad direction", "СОС", map[string]string{"direction": "sideways"}, http.StatusBadRequest},
+		{"binary", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", nil, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.UploadStream(rec, uploadRequest(t, "in.txt", tt.content, tt.fields))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.NotEqual(t, "text/event-stream", rec.Header().Get("Content-Type"))
+		})
+	}
+
+	t.Run("no file", func(t *testing.T) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		require.NoError(t, mw.WriteField("direction", "encode"))
+		require.NoError(t, mw.Close())
+		req := httptest.NewRequest(http.MethodPost, "/upload/stream", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		rec := httptest.NewRecorder()
+		h.UploadStream(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		cfg := testConfig(t)
+		cfg.MaxUploadSize = 1 << 10
+		rec := httptest.NewRecorder()
+		New(cfg).UploadStream(rec, uploadRequest(t, "big.txt", strings.Repeat("СОС ", 1000), nil))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+// cancelingRecorder cancels the request once the first event is written.
+type cancelingRecorder struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (r cancelingRecorder) Write(p []byte) (int, error) {
+	r.cancel()
+	return r.ResponseRecorder.Write(p)
+}
+
+func TestUploadStreamCancel(t *testing.T) {
+	cfg := testConfig(t)
+	h := New(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := uploadRequest(t, "big.txt", strings.Repeat("СОС ", 100000), nil).WithContext(ctx)
+
+	rec := cancelingRecorder{httptest.NewRecorder(), cancel}
+	h.UploadStream(rec, req)
+
+	events := readEvents(t, rec.Body.String())
+	require.NotEmpty(t, events)
+	for _, e := range events {
+		assert.NotEqual(t, "done", e.name)
+	}
+	assert.Less(t, len(events), 4)
+
+	entries, err := cfg.History.List(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestChunkWriterSplitRunes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &chunkWriter{
+		events:   &eventStream{w: rec, rc: http.NewResponseController(rec)},
+		progress: func() StreamProgress { return StreamProgress{} },
+	}
+
+	text := []byte("ДА·−")
+	for _, part := range [][]byte{text[:1], text[1:3], text[3:6], text[6:]} {
+		n, err := w.Write(part)
+		require.NoError(t, err)
+		assert.Equal(t, len(part), n)
+	}
+	require.NoError(t, w.flush())
+
+	var got []string
+	for _, e := range readEvents(t, rec.Body.String()) {
+		if e.name == "chunk" {
+			var c StreamChunk
+			require.NoError(t, json.Unmarshal([]byte(e.data), &c))
+			got = append(got, c.Text)
+		}
+	}
+	assert.Equal(t, []string{"Д", "А·", "−"}, got)
+}
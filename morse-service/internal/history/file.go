@@ -0,0 +1,230 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	metaFile   = "entry.json"
+	inputFile  = "input.txt"
+	outputFile = "output.txt"
+)
+
+// FileStore keeps every entry in its own directory, named by the ID, with
+// the metadata in entry.json next to input.txt and output.txt. Prune only
+// removes directories with an entry.json, so that nothing else in dir is
+// lost.
+type FileStore struct {
+	dir       string
+	retention Retention
+	mu        sync.Mutex
+}
+
+type fileMeta struct {
+	Created   time.Time `json:"created"`
+	Direction string    `json:"direction"`
+	Alphabet  string    `json:"alphabet"`
+	Filename  string    `json:"filename"`
+	Warnings  int       `json:"warnings"`
+}
+
+// NewFileStore opens the store in dir, creating it if needed.
+func NewFileStore(dir string, r Retention) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{dir: dir, retention: r}
+	if err := s.Prune(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Save(_ context.Context, e Entry) (Entry, error) {
+	e = prepare(e)
+
+	meta, err := json.MarshalIndent(fileMeta{
+		Created:   e.Created,
+		Direction: e.Direction,
+		Alphabet:  e.Alphabet,
+		Filename:  e.Filename,
+		Warnings:  e.Warnings,
+	}, "", "  ")
+	if err != nil {
+		return Entry{}, err
+	}
+
+	// The entry is written to a hidden directory first, so that it shows
+	// up complete or not at all.
+	tmp, err := os.MkdirTemp(s.dir, "."+e.ID+"-")
+	if err != nil {
+		return Entry{}, err
+	}
+
+	for name, data := range map[string][]byte{
+		metaFile:   meta,
+		inputFile:  []byte(e.Input),
+		outputFile: []byte(e.Output),
+	} {
+		if err := os.WriteFile(filepath.Join(tmp, name), data, 0o644); err != nil {
+			_ = os.RemoveAll(tmp)
+			return Entry{}, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Rename(tmp, filepath.Join(s.dir, e.ID)); err != nil {
+		_ = os.RemoveAll(tmp)
+		return Entry{}, err
+	}
+
+	return e, nil
+}
+
+func (s *FileStore) Get(_ context.Context, id string) (Entry, error) {
+	if !validID(id) {
+		return Entry{}, ErrNotFound
+	}
+
+	e, err := s.read(id)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	input, err := os.ReadFile(filepath.Join(s.dir, id, inputFile))
+	if err == nil {
+		var output []byte
+		output, err = os.ReadFile(filepath.Join(s.dir, id, outputFile))
+		e.Input, e.Output = string(input), string(output)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return Entry{}, ErrNotFound
+	}
+
+	return e, err
+}
+
+func (s *FileStore) List(_ context.Context, limit int) ([]Entry, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, min(limit, len(ids)))
+	for _, id := range slices.Backward(ids) {
+		if len(entries) == limit {
+			break
+		}
+
+		e, err := s.read(id)
+		if errors.Is(err, ErrNotFound) {
+			// Removed by retention since the directory was listed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+func (s *FileStore) read(id string) (Entry, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, id, metaFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Entry{}, ErrNotFound
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var meta fileMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return Entry{}, fmt.Errorf("entry %s: %w", id, err)
+	}
+
+	return Entry{
+		ID:        id,
+		Created:   meta.Created.UTC(),
+		Direction: meta.Direction,
+		Alphabet:  meta.Alphabet,
+		Filename:  meta.Filename,
+		Warnings:  meta.Warnings,
+	}, nil
+}
+
+// ids returns the IDs of the stored entries, oldest first. Other files in
+// the directory are ignored.
+func (s *FileStore) ids() ([]string, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, de := range dirEntries {
+		if de.IsDir() && validID(de.Name()) {
+			ids = append(ids, de.Name())
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *FileStore) Prune(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.ids()
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, id := range all {
+		if _, err := os.Lstat(filepath.Join(s.dir, id, metaFile)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	keep := len(ids)
+	if s.retention.MaxEntries > 0 {
+		keep = min(keep, s.retention.MaxEntries)
+	}
+
+	cutoff := s.retention.cutoff()
+
+	var errs []error
+	for i, id := range ids {
+		if i >= len(ids)-keep && !idTime(id).Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.dir, id)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
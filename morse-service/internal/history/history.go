@@ -0,0 +1,139 @@
+// Package history keeps the results of past conversions.
+package history
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound       = errors.New("history entry not found")
+	ErrUnknownBackend = errors.New("unknown history backend")
+)
+
+// Backends lists the names accepted by Open.
+var Backends = []string{"file", "sqlite"}
+
+// Entry is a single conversion. The ID is assigned by the store.
+type Entry struct {
+	ID        string
+	Created   time.Time
+	Direction string
+	Alphabet  string
+	Filename  string
+	Input     string
+	Output    string
+	Warnings  int
+}
+
+// Retention limits what a store keeps; entries beyond the limits are removed
+// by Prune, which the stores also run when they are opened. Zero values mean
+// no limit.
+type Retention struct {
+	MaxAge     time.Duration
+	MaxEntries int
+}
+
+type Store interface {
+	// Save stores e under a new ID and returns it with ID and Created set.
+	// A non-zero Created is kept.
+	Save(ctx context.Context, e Entry) (Entry, error)
+	// Get returns the entry with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (Entry, error)
+	// List returns up to limit entries, newest first, without Input and
+	// Output.
+	List(ctx context.Context, limit int) ([]Entry, error)
+	// Prune removes the entries beyond the retention limits.
+	Prune(ctx context.Context) error
+	Close() error
+}
+
+// Open opens the store of the named backend in dir. The file backend keeps
+// its entries in a history subdirectory, which it prunes.
+func Open(backend, dir string, r Retention) (Store, error) {
+	switch backend {
+	case "file":
+		return NewFileStore(filepath.Join(dir, "history"), r)
+	case "sqlite":
+		return NewSQLiteStore(filepath.Join(dir, "history.db"), r)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, backend)
+	}
+}
+
+const (
+	idTimeLen = 12
+	idSeqLen  = 8
+	idLen     = idTimeLen + idSeqLen
+)
+
+// lastID keeps IDs created in the same millisecond in order.
+var lastID struct {
+	sync.Mutex
+	ms  int64
+	seq uint32
+}
+
+// newID returns a unique ID for an entry created at t. IDs sort in creation
+// order: the time in milliseconds is followed by a sequence number that
+// starts at a random value every millisecond.
+func newID(t time.Time) string {
+	ms := t.UnixMilli()
+
+	lastID.Lock()
+	if ms == lastID.ms {
+		lastID.seq++
+	} else {
+		var b [4]byte
+		_, _ = rand.Read(b[:])
+		// The top bit is left clear so that the sequence does not wrap.
+		lastID.ms, lastID.seq = ms, binary.BigEndian.Uint32(b[:])>>1
+	}
+	seq := lastID.seq
+	lastID.Unlock()
+
+	return fmt.Sprintf("%0*x%0*x", idTimeLen, ms, idSeqLen, seq)
+}
+
+func validID(id string) bool {
+	if len(id) != idLen {
+		return false
+	}
+
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// idTime returns the creation time encoded in a valid ID.
+func idTime(id string) time.Time {
+	ms, _ := strconv.ParseInt(id[:idTimeLen], 16, 64)
+	return time.UnixMilli(ms).UTC()
+}
+
+// prepare sets the fields Save is responsible for. Times are kept with
+// millisecond precision, which is what the IDs carry.
+func prepare(e Entry) Entry {
+	if e.Created.IsZero() {
+		e.Created = time.Now()
+	}
+	e.Created = e.Created.UTC().Truncate(time.Millisecond)
+	e.ID = newID(e.Created)
+
+	return e
+}
+
+func (r Retention) cutoff() time.Time {
+	if r.MaxAge <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(-r.MaxAge)
+}
@@ -0,0 +1,187 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, backend string, r Retention) Store {
+	s, err := Open(backend, t.TempDir(), r)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func TestStore(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			s := open(t, backend, Retention{})
+
+			saved, err := s.Save(ctx, Entry{
+				Direction: "encode",
+				Alphabet:  "russian",
+				Filename:  "in.txt",
+				Input:     "СОС\n",
+				Output:    "... --- ...",
+				Warnings:  1,
+			})
+			require.NoError(t, err)
+			assert.True(t, validID(saved.ID))
+			assert.WithinDuration(t, time.Now(), saved.Created, time.Second)
+
+			got, err := s.Get(ctx, saved.ID)
+			require.NoError(t, err)
+			assert.Equal(t, saved, got)
+
+			second, err := s.Save(ctx, Entry{Direction: "decode", Input: "...", Output: "С"})
+			require.NoError(t, err)
+			assert.NotEqual(t, saved.ID, second.ID)
+
+			list, err := s.List(ctx, 10)
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, second.ID, list[0].ID)
+			assert.Equal(t, saved.ID, list[1].ID)
+			assert.Empty(t, list[1].Input)
+			assert.Empty(t, list[1].Output)
+			assert.Equal(t, "in.txt", list[1].Filename)
+
+			list, err = s.List(ctx, 1)
+			require.NoError(t, err)
+			assert.Len(t, list, 1)
+
+			for _, id := range []string{"", "../etc", "00000000000000000000", "zzzzzzzzzzzzzzzzzzzz"} {
+				_, err = s.Get(ctx, id)
+				assert.ErrorIs(t, err, ErrNotFound, id)
+			}
+		})
+	}
+}
+
+func TestRetention(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			s := open(t, backend, Retention{MaxAge: time.Hour, MaxEntries: 3})
+
+			old, err := s.Save(ctx, Entry{Created: time.Now().Add(-2 * time.Hour)})
+			require.NoError(t, err)
+			require.NoError(t, s.Prune(ctx))
+			_, err = s.Get(ctx, old.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			var ids []string
+			for i := range 5 {
+				e, err := s.Save(ctx, Entry{Created: time.Now().Add(time.Duration(i-10) * time.Minute)})
+				require.NoError(t, err)
+				ids = append(ids, e.ID)
+			}
+
+			// Saving does not prune.
+			list, err := s.List(ctx, 10)
+			require.NoError(t, err)
+			require.Len(t, list, 5)
+
+			require.NoError(t, s.Prune(ctx))
+			list, err = s.List(ctx, 10)
+			require.NoError(t, err)
+			require.Len(t, list, 3)
+			for i, e := range list {
+				assert.Equal(t, ids[4-i], e.ID)
+			}
+		})
+	}
+}
+
+func TestRetentionOnOpen(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			s, err := Open(backend, dir, Retention{})
+			require.NoError(t, err)
+			for range 3 {
+				_, err := s.Save(ctx, Entry{})
+				require.NoError(t, err)
+			}
+			require.NoError(t, s.Close())
+
+			s, err = Open(backend, dir, Retention{MaxEntries: 1})
+			require.NoError(t, err)
+			defer s.Close()
+
+			list, err := s.List(ctx, 10)
+			require.NoError(t, err)
+			assert.Len(t, list, 1)
+		})
+	}
+}
+
+func TestConcurrentSaves(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			s := open(t, backend, Retention{})
+
+			var wg sync.WaitGroup
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := s.Save(ctx, Entry{Input: "a", Output: ".-"})
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+
+			list, err := s.List(ctx, 100)
+			require.NoError(t, err)
+			assert.Len(t, list, 20)
+		})
+	}
+}
+
+func TestFileStoreIgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), nil, 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "static"), 0o755))
+	// Named like an old entry, but not written by the store.
+	other := filepath.Join(dir, "000000000001"+"00000000")
+	require.NoError(t, os.Mkdir(other, 0o755))
+
+	s, err := NewFileStore(dir, Retention{MaxEntries: 1})
+	require.NoError(t, err)
+
+	_, err = s.Save(context.Background(), Entry{})
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(dir, "index.html"))
+	assert.DirExists(t, filepath.Join(dir, "static"))
+	assert.DirExists(t, other)
+}
+
+func TestOpenFileSubdirectory(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open("file", dir, Retention{})
+	require.NoError(t, err)
+	defer s.Close()
+
+	e, err := s.Save(context.Background(), Entry{Input: "a", Output: ".-"})
+	require.NoError(t, err)
+	assert.DirExists(t, filepath.Join(dir, "history", e.ID))
+}
+
+func TestOpenUnknownBackend(t *testing.T) {
+	_, err := Open("redis", t.TempDir(), Retention{})
+	assert.ErrorIs(t, err, ErrUnknownBackend)
+}
@@ -0,0 +1,138 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS history (
+	id        TEXT PRIMARY KEY,
+	created   INTEGER NOT NULL,
+	direction TEXT NOT NULL,
+	alphabet  TEXT NOT NULL,
+	filename  TEXT NOT NULL,
+	input     TEXT NOT NULL,
+	output    TEXT NOT NULL,
+	warnings  INTEGER NOT NULL
+)`
+
+// SQLiteStore keeps the entries in a single SQLite database file. Created
+// is stored in Unix milliseconds.
+type SQLiteStore struct {
+	db        *sql.DB
+	retention Retention
+}
+
+func NewSQLiteStore(path string, r Retention) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection avoids busy errors.
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{db: db, retention: r}
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	if err := s.Prune(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, e Entry) (Entry, error) {
+	e = prepare(e)
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO history (id, created, direction, alphabet, filename, input, output, warnings)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.Created.UnixMilli(), e.Direction, e.Alphabet, e.Filename, e.Input, e.Output, e.Warnings)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return e, nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, id string) (Entry, error) {
+	var (
+		e       Entry
+		created int64
+	)
+
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, created, direction, alphabet, filename, input, output, warnings
+		FROM history WHERE id = ?`, id,
+	).Scan(&e.ID, &created, &e.Direction, &e.Alphabet, &e.Filename, &e.Input, &e.Output, &e.Warnings)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, ErrNotFound
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Created = time.UnixMilli(created).UTC()
+
+	return e, nil
+}
+
+func (s *SQLiteStore) List(ctx context.Context, limit int) ([]Entry, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, created, direction, alphabet, filename, warnings
+		FROM history ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var (
+			e       Entry
+			created int64
+		)
+		if err := rows.Scan(&e.ID, &created, &e.Direction, &e.Alphabet, &e.Filename, &e.Warnings); err != nil {
+			return nil, err
+		}
+		e.Created = time.UnixMilli(created).UTC()
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) Prune(ctx context.Context) error {
+	if cutoff := s.retention.cutoff(); !cutoff.IsZero() {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM history WHERE created < ?`, cutoff.UnixMilli()); err != nil {
+			return err
+		}
+	}
+
+	if s.retention.MaxEntries > 0 {
+		_, err := s.db.ExecContext(ctx,
+			`DELETE FROM history WHERE id NOT IN (SELECT id FROM history ORDER BY id DESC LIMIT ?)`,
+			s.retention.MaxEntries)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+// Package metrics keeps counters and histograms in process and exposes them
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds of latency histograms, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry is a set of metrics exposed together.
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]bool
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic("metrics: " + name + " registered twice")
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// ServeHTTP writes all metrics in registration order.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", ContentType)
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	_ = bw.Flush()
+}
+
+// vec holds one series per combination of label values.
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series[T]
+}
+
+type series[T any] struct {
+	values []string
+	v      T
+}
+
+func newVec[T any](name, help string, labels []string) vec[T] {
+	return vec[T]{name: name, help: help, labels: labels, series: make(map[string]*series[T])}
+}
+
+// with returns the series for the label values; the caller holds mu.
+func (v *vec[T]) with(values []string) *series[T] {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series[T]{values: slices.Clone(values)}
+		v.series[key] = s
+	}
+
+	return s
+}
+
+// sorted returns copies of the series ordered by label values; clone
+// copies what a value refers to.
+func (v *vec[T]) sorted(clone func(T) T) []series[T] {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	out := make([]series[T], 0, len(v.series))
+	for _, s := range v.series {
+		out = append(out, series[T]{values: s.values, v: clone(s.v)})
+	}
+	slices.SortFunc(out, func(a, b series[T]) int { return slices.Compare(a.values, b.values) })
+
+	return out
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	vec[float64]
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec[float64](name, help, labels)}
+	r.register(name, c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter; negative deltas are ignored, as counters only
+// go up.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.with(labelValues).v += delta
+}
+
+// Value returns the current count for the label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.with(labelValues).v
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	for _, s := range c.sorted(func(v float64) float64 { return v }) {
+		writeSample(w, c.name, c.labels, s.values, "", "", s.v)
+	}
+}
+
+// CounterFunc is a counter kept elsewhere and read when scraped.
+type CounterFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) *CounterFunc {
+	c := &CounterFunc{name: name, help: help, fn: fn}
+	r.register(name, c)
+	return c
+}
+
+func (c *CounterFunc) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	writeSample(w, c.name, nil, nil, "", "", c.fn())
+}
+
+// HistogramVec counts observations in buckets, partitioned by labels.
+type HistogramVec struct {
+	vec[histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h histogram) clone() histogram {
+	h.counts = slices.Clone(h.counts)
+	return h
+}
+
+// NewHistogramVec creates a histogram with the given bucket upper bounds,
+// which must be sorted; the +Inf bucket is implied.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !slices.IsSorted(buckets) {
+		panic("metrics: buckets of " + name + " are not sorted")
+	}
+
+	h := &HistogramVec{vec: newVec[histogram](name, help, labels), buckets: slices.Clone(buckets)}
+	r.register(name, h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.with(labelValues)
+	if s.v.counts == nil {
+		s.v.counts = make([]uint64, len(h.buckets))
+	}
+
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.v.counts[i]++
+	}
+	s.v.count++
+	s.v.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, s := range h.sorted(histogram.clone) {
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.v.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, s.values, "le", formatFloat(le), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.values, "le", "+Inf", float64(s.v.count))
+		writeSample(w, h.name+"_sum", h.labels, s.values, "", "", s.v.sum)
+		writeSample(w, h.name+"_count", h.labels, s.values, "", "", float64(s.v.count))
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeSample writes a line of the exposition format; extra is an
+// additional label, like the "le" of a histogram bucket.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extra, extraValue string, v float64) {
+	w.WriteString(name)
+
+	if len(labels) != 0 || extra != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i != 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, l, values[i])
+		}
+		if extra != "" {
+			if len(labels) != 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extra, extraValue)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	labelEscaper.WriteString(w, value)
+	w.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
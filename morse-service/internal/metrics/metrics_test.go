@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+
+	return rec.Body.String()
+}
+
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.NewCounterVec("requests_total", "Requests.\nAll of them.", "route", "status")
+	requests.Inc("/upload", "200")
+	requests.Add(2, "/", "200")
+	requests.Inc("/upload", "429")
+	requests.Add(-5, "/", "200")
+	requests.Inc(`a"b\c`+"\n", "500")
+
+	latency := reg.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.Observe(0.05, "/")
+	latency.Observe(0.1, "/")
+	latency.Observe(0.5, "/")
+	latency.Observe(3, "/")
+
+	n := 7.0
+	reg.NewCounterFunc("external_total", "Counted elsewhere.", func() float64 { return n })
+
+	assert.Equal(t, `# HELP requests_total Requests.\nAll of them.
+# TYPE requests_total counter
+requests_total{route="/",status="200"} 2
+requests_total{route="/upload",status="200"} 1
+requests_total{route="/upload",status="429"} 1
+requests_total{route="a\"b\\c\n",status="500"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/",le="0.1"} 2
+latency_seconds_bucket{route="/",le="1"} 3
+latency_seconds_bucket{route="/",le="+Inf"} 4
+latency_seconds_sum{route="/"} 3.65
+latency_seconds_count{route="/"} 4
+# HELP external_total Counted elsewhere.
+# TYPE external_total counter
+external_total 7
+`, scrape(t, reg))
+
+	assert.Equal(t, 2.0, requests.Value("/", "200"))
+}
+
+func TestRegisterTwice(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounterVec("x_total", "X.")
+
+	assert.Panics(t, func() { reg.NewCounterVec("x_total", "X again.") })
+	assert.Panics(t, func() { reg.NewHistogramVec("h", "H.", []float64{2, 1}) })
+}
+
+func TestWrongLabelCount(t *testing.T) {
+	c := NewRegistry().NewCounterVec("x_total", "X.", "a", "b")
+	assert.Panics(t, func() { c.Inc("only one") })
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounterVec("x_total", "X.", "n")
+	h := reg.NewHistogramVec("h", "H.", DefaultBuckets, "n")
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				c.Inc("a")
+				h.Observe(0.01, "a")
+				_ = scrape(t, reg)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 8000.0, c.Value("a"))
+	assert.Contains(t, scrape(t, reg), `h_count{n="a"} 8000`)
+}
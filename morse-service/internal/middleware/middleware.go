@@ -0,0 +1,234 @@
+// Package middleware wraps HTTP handlers with request IDs, access logging,
+// metrics and panic recovery.
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"sprint6/internal/metrics"
+)
+
+// RequestIDHeader carries the request ID. An ID sent by the client, e.g.
+// by a proxy in front of the service, is kept if it is sane.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 64
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	loggerKey
+)
+
+// Chain applies the middlewares so that the first one sees the request
+// first.
+func Chain(h http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+
+	return h
+}
+
+// RequestID gives every request an ID, sets it on the response and adds
+// it to the request logger.
+func RequestID(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := context.WithValue(r.Context(), requestIDKey, id)
+			ctx = context.WithValue(ctx, loggerKey, logger.With("request_id", id))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Logging logs every request once it is served.
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := wrap(w)
+
+		next.ServeHTTP(rec, r)
+
+		level := slog.LevelInfo
+		if rec.status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		Logger(r.Context()).LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int64("bytes", rec.bytes),
+			slog.String("remote", r.RemoteAddr),
+		)
+	})
+}
+
+// Recover turns a panic in a handler into a 500 response and logs it with
+// the stack trace. http.ErrAbortHandler is passed on, as it is meant to
+// abort the response.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := wrap(w)
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(v)
+			}
+
+			Logger(r.Context()).Error("panic", "panic", v, "stack", string(debug.Stack()))
+			if !rec.wrote {
+				http.Error(rec, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// Metrics counts the requests and measures their latency per route, the
+// pattern that matched the request, and status.
+func Metrics(reg *metrics.Registry) func(http.Handler) http.Handler {
+	requests := reg.NewCounterVec("http_requests_total",
+		"HTTP requests served.", "route", "status")
+	latency := reg.NewHistogramVec("http_request_duration_seconds",
+		"Time to serve HTTP requests.", metrics.DefaultBuckets, "route", "status")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := wrap(w)
+
+			next.ServeHTTP(rec, r)
+
+			// The mux sets the pattern on the request it was given.
+			route := r.Pattern
+			if route == "" {
+				route = "unmatched"
+			}
+			status := strconv.Itoa(rec.status())
+
+			requests.Inc(route, status)
+			latency.Observe(time.Since(start).Seconds(), route, status)
+		})
+	}
+}
+
+// RequestIDFrom returns the ID of the request ctx belongs to, if any.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Logger returns the logger of the request ctx belongs to, or the default
+// logger outside of requests.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID accepts short IDs of printable ASCII without spaces, so
+// that a client cannot forge log lines or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// wrap returns w as a recorder, reusing the one of an outer middleware.
+func wrap(w http.ResponseWriter) *recorder {
+	if rec, ok := w.(*recorder); ok {
+		return rec
+	}
+
+	return &recorder{ResponseWriter: w}
+}
+
+// recorder notes the status and size of a response.
+type recorder struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+	wrote bool
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if !r.wrote {
+		r.code, r.wrote = code, true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.wrote = true
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *recorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+
+	return r.code
+}
+
+func (r *recorder) Flush() {
+	r.wrote = true
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Hijack hands the connection over to the handler, as for a WebSocket; the
+// request is then logged as switching protocols.
+func (r *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.code, r.wrote = http.StatusSwitchingProtocols, true
+	}
+	return conn, brw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
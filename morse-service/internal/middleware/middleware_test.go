@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logged serves a request through the full chain and returns the
+// response and the log records.
+func logged(t *testing.T, h http.Handler, req *http.Request) (*httptest.ResponseRecorder, []map[string]any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	rec := httptest.NewRecorder()
+	Chain(h, RequestID(logger), Logging, Recover).ServeHTTP(rec, req)
+
+	var records []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+
+	return rec, records
+}
+
+func TestLogging(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Logger(r.Context()).Info("handler")
+		assert.Len(t, RequestIDFrom(r.Context()), 16)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	rec, records := logged(t, h, httptest.NewRequest(http.MethodPost, "/upload?x=1", nil))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	id := rec.Header().Get(RequestIDHeader)
+	assert.Len(t, id, 16)
+
+	require.Len(t, records, 2)
+	assert.Equal(t, "handler", records[0]["msg"])
+	assert.Equal(t, id, records[0]["request_id"])
+
+	access := records[1]
+	assert.Equal(t, "request", access["msg"])
+	assert.Equal(t, "INFO", access["level"])
+	assert.Equal(t, id, access["request_id"])
+	assert.Equal(t, "POST", access["method"])
+	assert.Equal(t, "/upload", access["path"])
+	assert.EqualValues(t, http.StatusCreated, access["status"])
+	assert.EqualValues(t, 5, access["bytes"])
+	assert.Contains(t, access, "latency")
+}
+
+func TestRequestIDFromClient(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		header string
+		kept   bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"two words", false},
+		{"line\nbreak", false},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, tt.header)
+
+		rec, _ := logged(t, h, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if tt.kept {
+			assert.Equal(t, tt.header, id)
+		} else {
+			assert.Len(t, id, 16, "%q", tt.header)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	rec, records := logged(t, h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get(RequestIDHeader))
+
+	require.Len(t, records, 2)
+	assert.Equal(t, "panic", records[0]["msg"])
+	assert.Equal(t, "boom", records[0]["panic"])
+	assert.Contains(t, records[0]["stack"], "TestRecover")
+
+	assert.Equal(t, "ERROR", records[1]["level"])
+	assert.EqualValues(t, http.StatusInternalServerError, records[1]["status"])
+}
+
+func TestRecoverAfterWrite(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		panic("boom")
+	})
+
+	rec, records := logged(t, h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "partial", rec.Body.String())
+	require.Len(t, records, 2)
+	assert.Equal(t, "panic", records[0]["msg"])
+}
+
+func TestRecoverAbort(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		Recover(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestFlush(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("event"))
+		require.NoError(t, http.NewResponseController(w).Flush())
+	})
+
+	rec, _ := logged(t, h, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, rec.Flushed)
+}
+
+func TestHijack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		_ = brw.Flush()
+	})
+	done := make(chan struct{})
+	chain := Chain(h, RequestID(logger), Logging, Recover)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		chain.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "test")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	<-done
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.EqualValues(t, http.StatusSwitchingProtocols, record["status"])
+}
@@ -0,0 +1,222 @@
+// Package ratelimit protects expensive endpoints from clients that send
+// too many requests.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Config struct {
+	// Rate is the sustained number of requests per second a client IP may
+	// make, Burst how many it may make at once. A zero Rate disables the
+	// per-client limit.
+	Rate  float64
+	Burst int
+
+	// MaxConcurrent caps the requests served at the same time across all
+	// clients; zero means no cap.
+	MaxConcurrent int
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header
+	// names the client. Without them every request counts for its
+	// RemoteAddr, so behind a proxy all clients would share one bucket.
+	TrustedProxies []netip.Prefix
+}
+
+// Stats counts the requests the limiter let through and rejected.
+type Stats struct {
+	Allowed            uint64
+	RateLimited        uint64
+	ConcurrencyLimited uint64
+}
+
+// sweepInterval is how often buckets of clients that have been idle long
+// enough to be full again are dropped.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a per-client token bucket combined with a concurrency cap.
+type Limiter struct {
+	cfg   Config
+	now   func() time.Time
+	slots chan struct{}
+
+	mu        sync.Mutex
+	clients   map[string]*bucket
+	lastSweep time.Time
+
+	allowed, rateLimited, concurrencyLimited atomic.Uint64
+}
+
+func New(cfg Config) *Limiter {
+	l := &Limiter{
+		cfg:     cfg,
+		now:     time.Now,
+		clients: make(map[string]*bucket),
+	}
+	if cfg.Burst < 1 {
+		l.cfg.Burst = 1
+	}
+	if cfg.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+
+	return l
+}
+
+// Middleware rejects requests over the limits with 429 Too Many Requests
+// and a Retry-After header.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := l.clientIP(r)
+		if wait, ok := l.allow(client); !ok {
+			l.rateLimited.Add(1)
+			tooManyRequests(w, wait, "rate limit exceeded")
+			return
+		}
+
+		if l.slots != nil {
+			select {
+			case l.slots <- struct{}{}:
+				defer func() { <-l.slots }()
+			default:
+				// The client did not get to use the token.
+				l.refund(client)
+				l.concurrencyLimited.Add(1)
+				tooManyRequests(w, time.Second, "too many conversions in progress")
+				return
+			}
+		}
+
+		l.allowed.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) Stats() Stats {
+	return Stats{
+		Allowed:            l.allowed.Load(),
+		RateLimited:        l.rateLimited.Load(),
+		ConcurrencyLimited: l.concurrencyLimited.Load(),
+	}
+}
+
+// allow takes a token from the client's bucket. If there is none, it
+// returns how long until there is.
+func (l *Limiter) allow(client string) (time.Duration, bool) {
+	if l.cfg.Rate <= 0 {
+		return 0, true
+	}
+
+	now := l.now()
+	burst := float64(l.cfg.Burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.clients[client] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.cfg.Rate * float64(time.Second)), false
+	}
+	b.tokens--
+
+	return 0, true
+}
+
+// refund gives back a token allow took.
+func (l *Limiter) refund(client string) {
+	if l.cfg.Rate <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.clients[client]; ok {
+		b.tokens = math.Min(float64(l.cfg.Burst), b.tokens+1)
+	}
+}
+
+// sweep drops the buckets that would be full by now.
+func (l *Limiter) sweep(now time.Time) {
+	full := time.Duration(float64(l.cfg.Burst) / l.cfg.Rate * float64(time.Second))
+	for client, b := range l.clients {
+		if now.Sub(b.last) >= full {
+			delete(l.clients, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// clientIP is the IP address the request came from, without the port.
+// Requests from trusted proxies come from the last address in
+// X-Forwarded-For that is not a trusted proxy itself.
+func (l *Limiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !l.trusted(host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		host = hop
+		if !l.trusted(hop) {
+			break
+		}
+	}
+
+	return host
+}
+
+func (l *Limiter) trusted(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, p := range l.cfg.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, msg string) {
+	seconds := max(1, int(math.Ceil(wait.Seconds())))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
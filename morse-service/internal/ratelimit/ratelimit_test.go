@@ -0,0 +1,178 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newLimiter(cfg Config) (*Limiter, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := New(cfg)
+	l.now = c.now
+	return l, c
+}
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte("ok"))
+})
+
+func request(h http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+	req.RemoteAddr = remoteAddr
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	l, clk := newLimiter(Config{Rate: 0.5, Burst: 2})
+	h := l.Middleware(ok)
+
+	assert.Equal(t, http.StatusOK, request(h, "192.0.2.1:1000").Code)
+	assert.Equal(t, http.StatusOK, request(h, "192.0.2.1:1001").Code)
+
+	rec := request(h, "192.0.2.1:1002")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+
+	// Other clients have buckets of their own.
+	assert.Equal(t, http.StatusOK, request(h, "192.0.2.2:1000").Code)
+
+	clk.advance(time.Second)
+	rec = request(h, "192.0.2.1:1003")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	clk.advance(time.Second)
+	assert.Equal(t, http.StatusOK, request(h, "192.0.2.1:1004").Code)
+
+	assert.Equal(t, Stats{Allowed: 4, RateLimited: 2}, l.Stats())
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	l, _ := newLimiter(Config{})
+	h := l.Middleware(ok)
+
+	for range 100 {
+		require.Equal(t, http.StatusOK, request(h, "192.0.2.1:1000").Code)
+	}
+	assert.Equal(t, Stats{Allowed: 100}, l.Stats())
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	l, _ := newLimiter(Config{MaxConcurrent: 2})
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request(h, "192.0.2.1:1000")
+		}()
+		<-entered
+	}
+
+	rec := request(h, "192.0.2.2:1000")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	close(release)
+	wg.Wait()
+
+	go func() { <-entered }()
+	assert.Equal(t, http.StatusOK, request(h, "192.0.2.2:1000").Code)
+
+	assert.Equal(t, Stats{Allowed: 3, ConcurrencyLimited: 1}, l.Stats())
+}
+
+func TestSweep(t *testing.T) {
+	l, clk := newLimiter(Config{Rate: 1, Burst: 5})
+	h := l.Middleware(ok)
+
+	request(h, "192.0.2.1:1000")
+	clk.advance(30 * time.Second)
+	request(h, "192.0.2.2:1000")
+	clk.advance(sweepInterval)
+	request(h, "192.0.2.3:1000")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	assert.Len(t, l.clients, 1)
+	assert.Contains(t, l.clients, "192.0.2.3")
+}
+
+func TestConcurrencyLimitRefundsToken(t *testing.T) {
+	l, _ := newLimiter(Config{Rate: 0.001, Burst: 1, MaxConcurrent: 1})
+
+	l.slots <- struct{}{}
+	h := l.Middleware(ok)
+	require.Equal(t, http.StatusTooManyRequests, request(h, "192.0.2.1:1000").Code)
+	<-l.slots
+
+	// The token was given back, so the retry is served.
+	assert.Equal(t, http.StatusOK, request(h, "192.0.2.1:1000").Code)
+	assert.Equal(t, Stats{Allowed: 1, ConcurrencyLimited: 1}, l.Stats())
+}
+
+func TestClientIP(t *testing.T) {
+	l := New(Config{TrustedProxies: []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::1/128"),
+	}})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct", "192.0.2.1:1000", nil, "192.0.2.1"},
+		{"untrusted proxy", "192.0.2.1:1000", []string{"198.51.100.7"}, "192.0.2.1"},
+		{"trusted proxy", "10.0.0.1:1000", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"spoofed hop", "10.0.0.1:1000", []string{"203.0.113.9, 198.51.100.7"}, "198.51.100.7"},
+		{"proxy chain", "10.0.0.1:1000", []string{"198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"several headers", "10.0.0.1:1000", []string{"198.51.100.7", "10.0.0.2"}, "198.51.100.7"},
+		{"no header", "10.0.0.1:1000", nil, "10.0.0.1"},
+		{"garbage", "10.0.0.1:1000", []string{"unknown"}, "10.0.0.1"},
+		{"ipv6 proxy", "[2001:db8::1]:1000", []string{"198.51.100.7"}, "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			assert.Equal(t, tt.want, l.clientIP(req))
+		})
+	}
+}
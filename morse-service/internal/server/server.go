@@ -1,31 +1,249 @@
 package server
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"sprint6/internal/archive"
+	"sprint6/internal/config"
 	"sprint6/internal/handlers"
+	"sprint6/internal/history"
+	"sprint6/internal/metrics"
+	"sprint6/internal/middleware"
+	"sprint6/internal/ratelimit"
 )
 
 type Server struct {
-	Logger *log.Logger
+	Logger *slog.Logger
 	HTTP   *http.Server
+
+	// ShutdownDelay is how long readiness reports failure before the
+	// listener closes, so that load balancers stop sending traffic.
+	// DrainTimeout bounds the wait for in-flight requests afterwards.
+	ShutdownDelay time.Duration
+	DrainTimeout  time.Duration
+
+	history  history.Store
+	limiter  *ratelimit.Limiter
+	ready    atomic.Bool
+	stopping atomic.Bool
 }
 
-func New(logger *log.Logger) *Server {
+// New opens the conversion history and sets up the routes behind request
+// IDs, access logging, metrics and panic recovery. The history is closed when the
+// server stops.
+func New(logger *slog.Logger, cfg config.Config) (*Server, error) {
+	store, err := history.Open(cfg.HistoryBackend, cfg.OutputDir, history.Retention{
+		MaxAge:     cfg.HistoryMaxAge,
+		MaxEntries: cfg.HistoryMaxEntries,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+
+	reg := metrics.NewRegistry()
+
+	h := handlers.New(handlers.Config{
+		MaxUploadSize: cfg.MaxUploadSize,
+		StaticDir:     cfg.StaticDir,
+		History:       store,
+		Metrics:       handlers.NewMetrics(reg),
+		BatchLimits: archive.Limits{
+			MaxEntries: cfg.BatchMaxEntries,
+			MaxSize:    cfg.BatchMaxSize,
+		},
+		MaxKeyerSessions: cfg.MaxKeyerSessions,
+	})
+
+	s := &Server{
+		Logger:        logger,
+		ShutdownDelay: cfg.ShutdownDelay,
+		DrainTimeout:  cfg.DrainTimeout,
+		history:       store,
+		limiter: ratelimit.New(ratelimit.Config{
+			Rate:           cfg.RateLimit,
+			Burst:          cfg.RateBurst,
+			MaxConcurrent:  cfg.MaxConversions,
+			TrustedProxies: cfg.TrustedProxies,
+		}),
+	}
+
+	reg.NewCounterFunc("morse_rate_limited_requests_total",
+		"Conversion requests rejected for exceeding the per-client rate.",
+		func() float64 { return float64(s.limiter.Stats().RateLimited) })
+	reg.NewCounterFunc("morse_concurrency_limited_requests_total",
+		"Conversion requests rejected for exceeding the concurrent conversions cap.",
+		func() float64 { return float64(s.limiter.Stats().ConcurrencyLimited) })
+
+	// Only the conversions are limited; they are what is expensive.
+	limited := func(h http.HandlerFunc) http.Handler {
+		return s.limiter.Middleware(h)
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", handlers.Ind)
-	mux.HandleFunc("/upload", handlers.Upload)
+	mux.HandleFunc("/", h.Ind)
+	mux.Handle("/upload", limited(h.Upload))
+	mux.Handle("/upload/stream", limited(h.UploadStream))
+	mux.Handle("/batch", limited(h.Batch))
+	mux.Handle("/audio", limited(h.Audio))
+	mux.Handle("/decode-audio", limited(h.DecodeAudio))
+	mux.Handle("/api/v1/convert", limited(h.Convert))
+	mux.HandleFunc("/ws/keyer", h.Keyer)
+	// The history holds what was uploaded, so it is only served when
+	// enabled.
+	if cfg.HistoryAPI {
+		mux.HandleFunc("/history", h.History)
+		mux.HandleFunc("/history/{id}", h.HistoryEntry)
+	} else {
+		mux.Handle("/history", http.NotFoundHandler())
+		mux.Handle("/history/", http.NotFoundHandler())
+	}
+	mux.HandleFunc("/livez", s.livez)
+	mux.HandleFunc("/readyz", s.readyz)
+	mux.Handle("GET /metrics", reg)
+
+	handler := middleware.Chain(mux,
+		middleware.RequestID(logger),
+		middleware.Logging,
+		middleware.Metrics(reg),
+		middleware.Recover,
+	)
+
+	s.HTTP = &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      handler,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+	s.HTTP.RegisterOnShutdown(h.Shutdown)
+
+	return s, nil
+}
+
+// Run listens on the configured address and serves until ctx is done or
+// the process gets SIGINT or SIGTERM, then shuts down gracefully.
+func (s *Server) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		_ = s.history.Close()
+		return err
+	}
+
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is done. On shutdown it first reports not
+// ready for ShutdownDelay, then stops accepting connections and waits up
+// to DrainTimeout for in-flight requests.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	defer func() {
+		if err := s.history.Close(); err != nil {
+			s.Logger.Error("close history", "error", err)
+		}
+	}()
+
+	pruneCtx, stopPrune := context.WithCancel(context.Background())
+	pruned := make(chan struct{})
+	go func() {
+		defer close(pruned)
+		s.pruneHistory(pruneCtx)
+	}()
+	defer func() {
+		stopPrune()
+		<-pruned
+	}()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.HTTP.Serve(ln)
+	}()
+
+	s.ready.Store(true)
+	s.Logger.Info("listening", "addr", ln.Addr().String())
+
+	select {
+	case err := <-errc:
+		s.ready.Store(false)
+		return err
+	case <-ctx.Done():
+	}
+
+	s.ready.Store(false)
+	s.stopping.Store(true)
+	s.Logger.Info("shutting down", "shutdown_delay", s.ShutdownDelay, "drain_timeout", s.DrainTimeout)
+
+	time.Sleep(s.ShutdownDelay)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.DrainTimeout)
+	defer cancel()
+
+	if err := s.HTTP.Shutdown(drainCtx); err != nil {
+		_ = s.HTTP.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	st := s.limiter.Stats()
+	s.Logger.Info("shutdown complete",
+		"conversions", st.Allowed, "rate_limited", st.RateLimited, "concurrency_limited", st.ConcurrencyLimited)
+	return nil
+}
+
+// historyPruneInterval is how often the history retention is applied.
+const historyPruneInterval = time.Minute
+
+// pruneHistory applies the history retention every historyPruneInterval
+// until ctx is done.
+func (s *Server) pruneHistory(ctx context.Context) {
+	t := time.NewTicker(historyPruneInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := s.history.Prune(ctx); err != nil && ctx.Err() == nil {
+				s.Logger.Error("prune history", "error", err)
+			}
+		}
+	}
+}
+
+// livez reports that the process is alive; it stays healthy while
+// draining so that the process is not killed before it finishes.
+func (s *Server) livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if s.stopping.Load() {
+		_, _ = w.Write([]byte("shutting down"))
+		return
+	}
+	_, _ = w.Write([]byte("ok"))
+}
 
-	hs := &http.Server{
-		Addr:         ":8080",
-		Handler:      mux,
-		ErrorLog:     logger,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  15 * time.Second,
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
 	}
 
-	return &Server{Logger: logger, HTTP: hs}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok"))
 }
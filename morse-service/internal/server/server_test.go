@@ -0,0 +1,349 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/internal/archive"
+	"sprint6/internal/config"
+	"sprint6/internal/websocket"
+)
+
+type testServer struct {
+	*Server
+	url    string
+	cancel context.CancelFunc
+	done   <-chan error
+	active <-chan struct{}
+}
+
+// start serves cfg on a local port until the test cancels it. active gets
+// a value whenever a connection starts reading a request.
+func start(t *testing.T, cfg config.Config) testServer {
+	s, err := New(slog.New(slog.DiscardHandler), cfg)
+	require.NoError(t, err)
+
+	active := make(chan struct{}, 16)
+	s.HTTP.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateActive {
+			select {
+			case active <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	t.Cleanup(cancel)
+
+	return testServer{s, "http://" + ln.Addr().String(), cancel, done, active}
+}
+
+func testConfig(t *testing.T) config.Config {
+	cfg := config.Default()
+	cfg.OutputDir = t.TempDir()
+	cfg.StaticDir = "../.."
+	return cfg
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, string(body)
+}
+
+func TestInFlightUploadCompletes(t *testing.T) {
+	ts := start(t, testConfig(t))
+
+	var head bytes.Buffer
+	mw := multipart.NewWriter(&head)
+	_, err := mw.CreateFormFile("myFile", "in.txt")
+	require.NoError(t, err)
+
+	var tail bytes.Buffer
+	tail.WriteString("СОС")
+	tail.WriteString("\r\n--" + mw.Boundary() + "--\r\n")
+
+	// The body is sent in two parts, with the shutdown in between.
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, ts.url+"/upload", pr)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	res := make(chan result, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			res <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		res <- result{resp.StatusCode, string(body), err}
+	}()
+
+	_, err = pw.Write(head.Bytes())
+	require.NoError(t, err)
+	<-ts.active
+
+	ts.cancel()
+
+	select {
+	case err := <-ts.done:
+		t.Fatalf("server stopped with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_, err = pw.Write(tail.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, pw.Close())
+
+	r := <-res
+	require.NoError(t, r.err)
+	assert.Equal(t, http.StatusOK, r.status)
+	assert.Equal(t, "... --- ...", r.body)
+
+	require.NoError(t, <-ts.done)
+}
+
+func TestReadinessDuringShutdown(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ShutdownDelay = 300 * time.Millisecond
+	ts := start(t, cfg)
+
+	status, body := get(t, ts.url+"/readyz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", body)
+
+	ts.cancel()
+	require.Eventually(t, func() bool { return !ts.ready.Load() }, time.Second, time.Millisecond)
+
+	status, _ = get(t, ts.url+"/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	status, body = get(t, ts.url+"/livez")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "shutting down", body)
+
+	require.NoError(t, <-ts.done)
+
+	_, err := http.Get(ts.url + "/livez")
+	assert.Error(t, err)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DrainTimeout = 50 * time.Millisecond
+	ts := start(t, cfg)
+
+	// A request whose body never ends keeps the connection active.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	req, err := http.NewRequest(http.MethodPost, ts.url+"/upload", pr)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	go func() {
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	_, err = pw.Write([]byte("--x\r\n"))
+	require.NoError(t, err)
+	<-ts.active
+
+	ts.cancel()
+	assert.ErrorIs(t, <-ts.done, context.DeadlineExceeded)
+}
+
+func TestMetrics(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RateBurst = 1
+	cfg.RateLimit = 0.001
+	ts := start(t, cfg)
+
+	for _, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		resp, err := http.Post(ts.url+"/api/v1/convert", "application/json", strings.NewReader(`{"text": "СОС Q"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, want, resp.StatusCode)
+	}
+
+	status, body := get(t, ts.url+"/metrics")
+	require.Equal(t, http.StatusOK, status)
+
+	for _, want := range []string{
+		`http_requests_total{route="/api/v1/convert",status="200"} 1`,
+		`http_requests_total{route="/api/v1/convert",status="429"} 1`,
+		`http_request_duration_seconds_count{route="/api/v1/convert",status="200"} 1`,
+		`morse_conversions_total{direction="encode",alphabet="russian"} 1`,
+		`morse_input_bytes_total{direction="encode"} 8`,
+		`morse_output_bytes_total{direction="encode"} 11`,
+		`morse_unknown_symbols_total{direction="encode",alphabet="russian"} 1`,
+		`morse_rate_limited_requests_total 1`,
+		`morse_concurrency_limited_requests_total 0`,
+	} {
+		assert.Contains(t, body, want)
+	}
+}
+
+func TestKeyerSessionEndsOnShutdown(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ReadTimeout = 50 * time.Millisecond
+	ts := start(t, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(ts.url, "http")+"/ws/keyer", nil)
+	require.NoError(t, err)
+	defer c.Close(websocket.CloseNormal, "")
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// The session outlives the read timeout of requests.
+	time.Sleep(2 * cfg.ReadTimeout)
+	require.NoError(t, c.WriteMessage(websocket.TextMessage, []byte(`{"key": "down", "time": 0}`)))
+	_, msg, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), `"pending":""`)
+
+	ts.cancel()
+
+	_, _, err = c.ReadMessage()
+	var closed websocket.CloseError
+	require.ErrorAs(t, err, &closed)
+	assert.Equal(t, websocket.CloseGoingAway, closed.Code)
+	require.NoError(t, <-ts.done)
+}
+
+func TestUploadStream(t *testing.T) {
+	ts := start(t, testConfig(t))
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("myFile", "in.txt")
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("СОС"))
+	require.NoError(t, mw.Close())
+
+	resp, err := http.Post(ts.url+"/upload/stream", mw.FormDataContentType(), &body)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(data), "event: chunk\ndata: {\"text\":\"... --- ...\"}\n\n")
+	assert.Contains(t, string(data), "event: done\n")
+}
+
+func TestUploadStreamSlow(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ReadTimeout = 100 * time.Millisecond
+	ts := start(t, cfg)
+
+	var head bytes.Buffer
+	mw := multipart.NewWriter(&head)
+	_, err := mw.CreateFormFile("myFile", "in.txt")
+	require.NoError(t, err)
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write(head.Bytes())
+		// The body takes several times the read timeout to arrive.
+		for range 10 {
+			time.Sleep(cfg.ReadTimeout / 2)
+			_, _ = pw.Write([]byte(strings.Repeat("СОС ", 5000)))
+		}
+		_, _ = pw.Write([]byte("\r\n--" + mw.Boundary() + "--\r\n"))
+		_ = pw.Close()
+	}()
+
+	resp, err := http.Post(ts.url+"/upload/stream", mw.FormDataContentType(), pr)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(data), "event: done\n")
+	assert.NotContains(t, string(data), "event: error\n")
+}
+
+func TestBatch(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.BatchMaxEntries = 1
+	ts := start(t, cfg)
+
+	post := func(files ...string) *http.Response {
+		var upload bytes.Buffer
+		aw := archive.NewWriter(&upload, archive.Zip)
+		for _, name := range files {
+			require.NoError(t, aw.WriteFile(name, []byte("СОС")))
+		}
+		require.NoError(t, aw.Close())
+
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile("myFile", "in.zip")
+		require.NoError(t, err)
+		_, _ = fw.Write(upload.Bytes())
+		require.NoError(t, mw.Close())
+
+		resp, err := http.Post(ts.url+"/batch", mw.FormDataContentType(), &body)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+
+		return resp
+	}
+
+	resp := post("a.txt")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+
+	resp = post("a.txt", "b.txt")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestHistoryAPI(t *testing.T) {
+	cfg := testConfig(t)
+	ts := start(t, cfg)
+
+	status, _ := get(t, ts.url+"/history")
+	assert.Equal(t, http.StatusNotFound, status, "the history is off by default")
+
+	cfg = testConfig(t)
+	cfg.HistoryAPI = true
+	ts = start(t, cfg)
+
+	status, body := get(t, ts.url+"/history")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"entries": []}`, body)
+}
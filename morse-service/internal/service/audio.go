@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"strings"
+	"time"
+
+	"sprint6/pkg/audio"
+	"sprint6/pkg/morse"
+)
+
+const MaxAudioDuration = 10 * time.Minute
+
+var (
+	ErrAudioTooLong = errors.New("аудио слишком длинное")
+	ErrNoSignal     = errors.New("в аудио не найден сигнал")
+)
+
+type AudioOptions struct {
+	WPM        float64
+	Farnsworth float64
+	Tone       audio.Tone
+}
+
+var DefaultAudioOptions = AudioOptions{
+	WPM:  20,
+	Tone: audio.DefaultTone,
+}
+
+// Audio is Morse code checked to render as a WAV file of a known size.
+type Audio struct {
+	tone   audio.Tone
+	signal iter.Seq[morse.Element]
+	size   int64
+}
+
+// NewAudio prepares input to be written as a WAV file. Plain text is
+// encoded with c first; Morse input is keyed as is. All errors but those
+// of writing are reported here, before anything is written.
+func NewAudio(input string, c morse.Converter, opts AudioOptions) (*Audio, error) {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return nil, ErrEmptyInput
+	}
+
+	timing, err := morse.NewTiming(opts.WPM, opts.Farnsworth)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Tone.Validate(); err != nil {
+		return nil, err
+	}
+
+	d := Detect(trimmed, c)
+	if c, err = converterFor(d, c); err != nil {
+		return nil, err
+	}
+
+	code := trimmed
+	if d.Direction == DirectionEncode {
+		code = c.ToMorse(trimmed)
+	}
+
+	signal := c.Signal(code, timing)
+
+	var total time.Duration
+	for e := range signal {
+		if total += e.Dur; total > MaxAudioDuration {
+			return nil, ErrAudioTooLong
+		}
+	}
+
+	size, err := opts.Tone.Size(total)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Audio{tone: opts.Tone, signal: signal, size: size}, nil
+}
+
+// Size returns the size of the WAV file in bytes.
+func (a *Audio) Size() int64 {
+	return a.size
+}
+
+// Render writes the WAV file to w.
+func (a *Audio) Render(w io.Writer) error {
+	return a.tone.WriteSignal(w, a.signal)
+}
+
+// RenderAudio writes input as a WAV file, as prepared by NewAudio.
+func RenderAudio(dst io.Writer, input string, c morse.Converter, opts AudioOptions) error {
+	a, err := NewAudio(input, c, opts)
+	if err != nil {
+		return err
+	}
+
+	return a.Render(dst)
+}
+
+// DecodeAudio recognizes Morse code in a WAV file and decodes it with c.
+// A zero frequency selects envelope detection instead of a tuned filter.
+func DecodeAudio(src io.Reader, c morse.Converter, frequency float64) (text string, wpm float64, err error) {
+	opts := audio.DefaultDetectOptions
+	opts.Frequency = frequency
+
+	elements, err := audio.Detect(src, opts)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(elements) == 0 {
+		return "", 0, ErrNoSignal
+	}
+
+	code, wpm := c.FromElements(elements)
+	return c.ToText(code), wpm, nil
+}
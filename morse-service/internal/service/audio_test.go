@@ -0,0 +1,70 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/pkg/audio"
+	"sprint6/pkg/morse"
+)
+
+func TestNewAudio(t *testing.T) {
+	a, err := NewAudio("СОС", morse.DefaultConverter, DefaultAudioOptions)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, a.Render(&buf))
+	assert.Equal(t, a.Size(), int64(buf.Len()))
+
+	f, samples, err := audio.ReadWAV(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAudioOptions.Tone.SampleRate, f.SampleRate)
+	assert.NotEmpty(t, samples)
+}
+
+func TestNewAudioErrors(t *testing.T) {
+	slow := DefaultAudioOptions
+	slow.WPM = 5
+
+	tests := []struct {
+		name  string
+		input string
+		opts  AudioOptions
+		err   error
+	}{
+		{"empty", "  ", DefaultAudioOptions, ErrEmptyInput},
+		// 60 words of 12 s each.
+		{"too long", strings.Repeat("ПАРИС ", 60), slow, ErrAudioTooLong},
+		{"timing", "СОС", AudioOptions{Tone: audio.DefaultTone}, morse.ErrInvalidTiming},
+		{"tone", "СОС", AudioOptions{WPM: 20}, audio.ErrInvalidTone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAudio(tt.input, morse.DefaultConverter, tt.opts)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestDecodeAudio(t *testing.T) {
+	var wav bytes.Buffer
+	require.NoError(t, RenderAudio(&wav, "СОС", morse.DefaultConverter, DefaultAudioOptions))
+
+	text, wpm, err := DecodeAudio(&wav, morse.DefaultConverter, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "СОС", text)
+	assert.InDelta(t, DefaultAudioOptions.WPM, wpm, 1)
+
+	var silence bytes.Buffer
+	require.NoError(t, audio.DefaultTone.WriteWAV(&silence, []morse.Element{{Dur: time.Second}}))
+	_, _, err = DecodeAudio(&silence, morse.DefaultConverter, 0)
+	assert.ErrorIs(t, err, ErrNoSignal)
+
+	_, _, err = DecodeAudio(strings.NewReader("СОС"), morse.DefaultConverter, 0)
+	assert.ErrorIs(t, err, audio.ErrUnsupportedWAV)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"sprint6/pkg/morse"
+)
+
+// MinConfidence is the lowest confidence at which DirectionAuto picks a
+// direction; below it the input is rejected with ErrAmbiguousInput.
+const MinConfidence = 0.75
+
+var ErrAmbiguousInput = errors.New("не удалось определить, текст это или код Морзе")
+
+// Detection is the direction picked for an input and how sure the detector
+// is about it, from 0.5 (a guess) to 1. For decoding, Notation is the
+// notation the input is written in.
+type Detection struct {
+	Direction  Direction
+	Confidence float64
+	Notation   morse.Notation
+}
+
+// minBits is the fewest bits input must have to be taken for binary
+// notation when c does not use it: short numbers such as "1" or "101"
+// decode to letters as well.
+const minBits = 16
+
+// Detect tells plain text from Morse code. The input is decoded with c in
+// every notation, and the one that decodes most of it wins: every rune that
+// decodes counts for decoding, every rune of a token made of dots and dashes
+// that is no code counts for half. Binary notation is only tried if c uses
+// it or the input looks like a bit string.
+func Detect(input string, c morse.Converter) Detection {
+	best, score := c.Notation(), -1.0
+	for _, n := range append([]morse.Notation{c.Notation()}, morse.Notations()...) {
+		if n.Name() == morse.NotationBinary.Name() && n.Name() != c.Notation().Name() && !bitString(input) {
+			continue
+		}
+		nc, err := c.WithNotation(n)
+		if err != nil {
+			continue
+		}
+		if s := decodeScore(input, nc); s > score {
+			best, score = n, s
+		}
+	}
+
+	switch {
+	case score > 0.5:
+		return Detection{Direction: DirectionDecode, Confidence: score, Notation: best}
+	case score < 0:
+		return Detection{Direction: DirectionEncode, Confidence: 0, Notation: c.Notation()}
+	default:
+		return Detection{Direction: DirectionEncode, Confidence: 1 - score, Notation: c.Notation()}
+	}
+}
+
+// bitString reports whether input is shaped like binary notation: nothing
+// but ones, zeros and white space, at least minBits bits, and a "000" gap
+// between characters.
+func bitString(input string) bool {
+	bits := 0
+	for _, r := range input {
+		switch {
+		case r == '0' || r == '1':
+			bits++
+		case !unicode.IsSpace(r):
+			return false
+		}
+	}
+
+	return bits >= minBits && strings.Contains(input, "000")
+}
+
+// sample returns the head of input that the direction is detected by: at
+// most sniffSize bytes, cut at white space where there is some.
+func sample(input string) string {
+	if len(input) <= sniffSize {
+		return input
+	}
+
+	head := input[:sniffSize]
+	if i := strings.LastIndexFunc(head, unicode.IsSpace); i > 0 {
+		return head[:i]
+	}
+
+	return string(completeRunes([]byte(head)))
+}
+
+// decodeScore returns the share of input that c decodes, or a negative
+// number for input without anything to decode. Lines are decoded one by
+// one, so that line breaks separate words.
+func decodeScore(input string, c morse.Converter) float64 {
+	n := c.Notation()
+
+	var total, lost float64
+	for line := range strings.Lines(input) {
+		line = strings.TrimSpace(line)
+		for _, field := range strings.Fields(line) {
+			// Word marks count for neither direction.
+			if !morse.IsWordMark(field) {
+				total += float64(utf8.RuneCountInString(field))
+			}
+		}
+
+		_, report := c.ToTextStrict(line)
+
+		var lineLost float64
+		for _, issue := range report.Issues {
+			text := issue.Err.Text
+			size := float64(utf8.RuneCountInString(text))
+
+			if _, code := n.Parse(text); code {
+				lineLost += size / 2
+			} else {
+				lineLost += size
+			}
+		}
+		if len(report.Issues) != 0 {
+			// Beyond MaxReportedIssues the lost input is extrapolated.
+			lineLost *= float64(report.Total) / float64(len(report.Issues))
+		}
+		lost += lineLost
+	}
+
+	if total <= 0 {
+		return -1
+	}
+
+	return (total - lost) / total
+}
+
+// resolve returns the direction to convert input in and, for decoding, the
+// notation to read it in. A forced direction is taken as is, with full
+// confidence. Only the sample of a long input is looked at.
+func resolve(input string, direction Direction, c morse.Converter) (Detection, error) {
+	input = sample(input)
+
+	switch direction {
+	case DirectionAuto, "":
+	case DirectionEncode:
+		return Detection{Direction: direction, Confidence: 1, Notation: c.Notation()}, nil
+	case DirectionDecode:
+		d := Detect(input, c)
+		if d.Direction != DirectionDecode {
+			d.Notation = c.Notation()
+		}
+		return Detection{Direction: direction, Confidence: 1, Notation: d.Notation}, nil
+	default:
+		return Detection{}, fmt.Errorf("%w: %q", ErrInvalidDirection, direction)
+	}
+
+	d := Detect(input, c)
+	if d.Confidence < MinConfidence {
+		return d, fmt.Errorf("%w: уверенность %.2f, укажите направление %s или %s",
+			ErrAmbiguousInput, d.Confidence, DirectionEncode, DirectionDecode)
+	}
+
+	return d, nil
+}
+
+// converterFor returns c reading and writing in the detected notation.
+func converterFor(d Detection, c morse.Converter) (morse.Converter, error) {
+	if d.Notation.Name() == c.Notation().Name() {
+		return c, nil
+	}
+
+	return c.WithNotation(d.Notation)
+}
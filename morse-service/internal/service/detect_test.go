@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/pkg/morse"
+)
+
+func TestDetect(t *testing.T) {
+	bars := morse.MustNewConverter(morse.DefaultMorse, morse.WithDecoding(morse.ЪЬ, 'Ь'), morse.WithCharSeparator("|"))
+	binary, err := morse.DefaultConverter.WithNotation(morse.NotationBinary)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		input     string
+		c         morse.Converter
+		direction Direction
+		notation  morse.Notation
+		ambiguous bool
+	}{
+		{"text", "Привет, мир!", morse.DefaultConverter, DirectionEncode, morse.NotationStandard, false},
+		{"text with a dash", "Привет - мир", morse.DefaultConverter, DirectionEncode, morse.NotationStandard, false},
+		{"morse", "... --- ...", morse.DefaultConverter, DirectionDecode, morse.NotationStandard, false},
+		{"morse lines", "... --- ...\n.-- --- .-. -..\n", morse.DefaultConverter, DirectionDecode, morse.NotationStandard, false},
+		{"morse with slashes", ".-- --- .-. -.. / .-- --- .-. -..", morse.DefaultConverter, DirectionDecode, morse.NotationStandard, false},
+		{"morse with bars", "...|---|...", bars, DirectionDecode, morse.NotationStandard, false},
+		{"bars without the separator", "...|---|...", morse.DefaultConverter, DirectionEncode, morse.NotationStandard, false},
+		{"morse with a stray letter", "... --- ... x ... --- ... .-.. .-", morse.DefaultConverter, DirectionDecode, morse.NotationStandard, false},
+		{"middle dots and minus signs", "··· −−− ···", morse.DefaultConverter, DirectionDecode, morse.NotationUnicode, false},
+		{"underscores", "..._ .._", morse.DefaultConverter, DirectionDecode, morse.NotationUnderscore, false},
+		{"dit-dah", "di-di-dit dah-dah-dah di-di-dit", morse.DefaultConverter, DirectionDecode, morse.NotationDitDah, false},
+		{"binary", "1010100011101110111000101010000000111", morse.DefaultConverter, DirectionDecode, morse.NotationBinary, false},
+		{"numbers", "1 10 100 1000", morse.DefaultConverter, DirectionEncode, morse.NotationStandard, false},
+		{"one", "1", morse.DefaultConverter, DirectionEncode, morse.NotationStandard, false},
+		{"short bits", "101", morse.DefaultConverter, DirectionEncode, morse.NotationStandard, false},
+		{"bits without gaps", "1010101010101010", morse.DefaultConverter, DirectionEncode, morse.NotationStandard, false},
+		{"binary chosen", "101", binary, DirectionDecode, morse.NotationBinary, false},
+		{"half and half", "ab ...", morse.DefaultConverter, DirectionDecode, morse.NotationStandard, true},
+		{"only separators", "/ | /", morse.DefaultConverter, DirectionEncode, morse.NotationStandard, true},
+		{"ellipsis", "Итак...", morse.DefaultConverter, DirectionEncode, morse.NotationStandard, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Detect(tt.input, tt.c)
+			assert.Equal(t, tt.direction, d.Direction)
+			assert.Equal(t, tt.notation.Name(), d.Notation.Name())
+			assert.Equal(t, tt.ambiguous, d.Confidence < MinConfidence, "confidence %v", d.Confidence)
+		})
+	}
+}
+
+func TestConvertNotations(t *testing.T) {
+	for _, input := range []string{
+		"··· −−− ···",
+		"..._ .._",
+		"di-di-dit dah-dah-dah di-di-dit",
+		"10101000111011101110001010100000001110101",
+	} {
+		res, err := Convert(input, DirectionAuto, morse.DefaultConverter)
+		require.NoError(t, err, input)
+		assert.True(t, res.Report.Lossless(), input)
+		assert.NotEmpty(t, res.Output, input)
+	}
+
+	res, err := Convert("di-dah", DirectionDecode, morse.DefaultConverter)
+	require.NoError(t, err)
+	assert.Equal(t, "А", res.Output)
+	assert.Equal(t, morse.NotationDitDah.Name(), res.Notation.Name())
+}
+
+func TestDetectInvalidCodes(t *testing.T) {
+	valid := Detect("... --- ...", morse.DefaultConverter)
+	invalid := Detect("........ -------- ........", morse.DefaultConverter)
+
+	assert.Equal(t, DirectionDecode, invalid.Direction)
+	assert.Less(t, invalid.Confidence, valid.Confidence)
+}
+
+func TestConvertNumbers(t *testing.T) {
+	for _, input := range []string{"1", "101", "2024"} {
+		res, err := Convert(input, DirectionAuto, morse.DefaultConverter)
+		require.NoError(t, err, input)
+		assert.Equal(t, DirectionEncode, res.Direction, input)
+	}
+}
+
+func TestResolveSample(t *testing.T) {
+	// Only the head of a long input is looked at: the text after it does
+	// not change the detection.
+	input := strings.Repeat("... --- ... ", sniffSize/12+1) + strings.Repeat("Привет ", 1000)
+	d, err := resolve(input, DirectionAuto, morse.DefaultConverter)
+	require.NoError(t, err)
+	assert.Equal(t, DirectionDecode, d.Direction)
+
+	assert.Equal(t, "... ---", sample("... ---"))
+	assert.LessOrEqual(t, len(sample(input)), sniffSize)
+	assert.True(t, strings.HasPrefix(input[len(sample(input)):], " "), "cut inside a token")
+	assert.True(t, utf8.ValidString(sample(strings.Repeat("Ж", sniffSize))))
+}
+
+func TestConvertAmbiguous(t *testing.T) {
+	_, err := Convert("ab ...", DirectionAuto, morse.DefaultConverter)
+	require.ErrorIs(t, err, ErrAmbiguousInput)
+	assert.ErrorContains(t, err, "encode")
+
+	res, err := Convert("ab ...", DirectionEncode, morse.DefaultConverter)
+	require.NoError(t, err)
+	assert.Equal(t, DirectionEncode, res.Direction)
+	assert.Equal(t, 1.0, res.Confidence)
+}
+
+func TestConvertStream(t *testing.T) {
+	var out bytes.Buffer
+	d, report, err := ConvertStream(&out, strings.NewReader("  ... --- ... Q"), DirectionAuto, morse.DefaultConverter)
+	require.NoError(t, err)
+	assert.Equal(t, DirectionDecode, d.Direction)
+	assert.Equal(t, "СОС", out.String())
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, morse.Position{Offset: 14, Line: 1, Column: 15}, report.Issues[0].Position)
+
+	out.Reset()
+	d, _, err = ConvertStream(&out, strings.NewReader("... --- ..."), DirectionEncode, morse.DefaultConverter)
+	require.NoError(t, err)
+	assert.Equal(t, DirectionEncode, d.Direction)
+	assert.Equal(t, 1.0, d.Confidence)
+
+	_, _, err = ConvertStream(&out, strings.NewReader("ab ..."), DirectionAuto, morse.DefaultConverter)
+	assert.ErrorIs(t, err, ErrAmbiguousInput)
+
+	_, _, err = ConvertStream(&out, strings.NewReader("ab"), "sideways", morse.DefaultConverter)
+	assert.ErrorIs(t, err, ErrInvalidDirection)
+
+	// Longer than the head the direction is detected from.
+	blank := strings.Repeat(" ", 8<<10)
+	for _, direction := range []Direction{DirectionAuto, DirectionEncode, DirectionDecode} {
+		out.Reset()
+		_, _, err = ConvertStream(&out, strings.NewReader(blank), direction, morse.DefaultConverter)
+		assert.ErrorIs(t, err, ErrEmptyInput, direction)
+		assert.Empty(t, out.String())
+	}
+
+	out.Reset()
+	d, report, err = ConvertStream(&out, strings.NewReader(blank+"\n... --- ... Q"), DirectionAuto, morse.DefaultConverter)
+	require.NoError(t, err)
+	assert.Equal(t, DirectionDecode, d.Direction)
+	assert.Equal(t, "СОС", out.String())
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, morse.Position{Offset: 8<<10 + 13, Line: 2, Column: 13}, report.Issues[0].Position)
+}
@@ -1,35 +1,245 @@
 package service
 
 import (
+	"bufio"
 	"errors"
+	"io"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"sprint6/pkg/morse"
 )
 
-func isMorseLike(s string) bool {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return false
+const sniffSize = 4 << 10
+
+var (
+	ErrEmptyInput       = errors.New("пустые данные: нечего конвертировать")
+	ErrInvalidDirection = errors.New("неизвестное направление конвертации")
+)
+
+type Direction string
+
+const (
+	DirectionAuto   Direction = "auto"
+	DirectionEncode Direction = "encode"
+	DirectionDecode Direction = "decode"
+)
+
+// Result is the outcome of Convert. Report positions refer to the input
+// as passed to Convert.
+type Result struct {
+	Output string
+	Detection
+	Report morse.ConversionReport
+}
+
+func ConvertAuto(input string) (string, error) {
+	res, err := Convert(input, DirectionAuto, morse.DefaultConverter)
+	return res.Output, err
+}
+
+// Convert encodes or decodes input with c. DirectionAuto picks the
+// direction with Detect and fails with ErrAmbiguousInput if unsure.
+func Convert(input string, direction Direction, c morse.Converter) (Result, error) {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return Result{}, ErrEmptyInput
 	}
-	for _, r := range s {
-		switch r {
-		case '.', '-', ' ', '/', '\n', '\r', '\t':
+
+	d, err := resolve(trimmed, direction, c)
+	if err != nil {
+		return Result{Detection: d}, err
+	}
+
+	if c, err = converterFor(d, c); err != nil {
+		return Result{Detection: d}, err
+	}
+
+	res := Result{Detection: d}
+	if d.Direction == DirectionDecode {
+		res.Output, res.Report = c.ToTextStrict(trimmed)
+	} else {
+		res.Output, res.Report = c.ToMorseStrict(trimmed)
+	}
+
+	res.Report = shiftReport(res.Report, leadingSpace(input))
+
+	return res, nil
+}
+
+// Converter returns a converter for the named alphabet, configured like
+// morse.DefaultConverter and then by options. An empty name selects the
+// default alphabet.
+func Converter(alphabet string, options ...morse.ConverterOption) (morse.Converter, error) {
+	if alphabet == "" {
+		if len(options) == 0 {
+			return morse.DefaultConverter, nil
+		}
+		alphabet = morse.DefaultAlphabet
+	}
+
+	defaults := []morse.ConverterOption{
+		morse.WithProsigns(morse.Prosigns),
+		morse.WithLowercaseHandling(true),
+	}
+
+	return morse.NewConverterFor(alphabet, append(defaults, options...)...)
+}
+
+// ConvertStream works like Convert, but reads src incrementally. With
+// DirectionAuto the direction is detected by the first sniffSize bytes of
+// the input after the leading whitespace, however long that is. The report
+// positions refer to src as is, before whitespace trimming.
+func ConvertStream(dst io.Writer, src io.Reader, direction Direction, c morse.Converter) (Detection, morse.ConversionReport, error) {
+	br := bufio.NewReaderSize(src, sniffSize)
+	in := &trimReader{r: br}
+
+	err := in.skipSpace()
+	if errors.Is(err, io.EOF) {
+		return Detection{}, morse.ConversionReport{}, ErrEmptyInput
+	}
+	if err != nil {
+		return Detection{}, morse.ConversionReport{}, err
+	}
+
+	head, err := br.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return Detection{}, morse.ConversionReport{}, err
+	}
+
+	d, err := resolve(string(completeRunes(head)), direction, c)
+	if err != nil {
+		return d, morse.ConversionReport{}, err
+	}
+
+	if c, err = converterFor(d, c); err != nil {
+		return d, morse.ConversionReport{}, err
+	}
+
+	if d.Direction == DirectionDecode {
+		dec := c.NewDecoder(in)
+		_, err = io.Copy(dst, dec)
+		return d, in.untrim(dec.Report()), err
+	}
+
+	enc := c.NewEncoder(dst)
+	if _, err := io.Copy(enc, in); err != nil {
+		return d, in.untrim(enc.Report()), err
+	}
+	err = enc.Close()
+
+	return d, in.untrim(enc.Report()), err
+}
+
+func completeRunes(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+
+	return b
+}
+
+// trimReader strips leading and trailing whitespace from the stream,
+// matching strings.TrimSpace on the whole input.
+type trimReader struct {
+	r       *bufio.Reader
+	started bool
+	skipped morse.Position
+	space   []byte
+	out     []byte
+}
+
+func (t *trimReader) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		r, size, err := t.r.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+
+		if unicode.IsSpace(r) {
+			if t.started {
+				t.space = utf8.AppendRune(t.space, r)
+				continue
+			}
+
+			t.skipped = skip(t.skipped, r, size)
 			continue
-		default:
-			return false
 		}
+
+		t.started = true
+		t.out = append(t.out, t.space...)
+		t.space = t.space[:0]
+		t.out = utf8.AppendRune(t.out, r)
 	}
-	return strings.ContainsAny(s, ".-")
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+
+	return n, nil
 }
 
-func ConvertAuto(input string) (string, error) {
-	trimmed := strings.TrimSpace(input)
-	if trimmed == "" {
-		return "", errors.New("пустые данные: нечего конвертировать")
+// skipSpace reads past the leading whitespace, leaving the first other rune
+// to be read. It returns io.EOF if there is none.
+func (t *trimReader) skipSpace() error {
+	for {
+		r, size, err := t.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if !unicode.IsSpace(r) {
+			return t.r.UnreadRune()
+		}
+
+		t.skipped = skip(t.skipped, r, size)
+	}
+}
+
+// untrim moves report positions back to where they are in the untrimmed input.
+func (t *trimReader) untrim(report morse.ConversionReport) morse.ConversionReport {
+	return shiftReport(report, t.skipped)
+}
+
+// skip advances over a skipped rune. Line and Column of the result count
+// the newlines and the runes after the last one.
+func skip(skipped morse.Position, r rune, size int) morse.Position {
+	skipped.Offset += size
+	if r == '\n' {
+		skipped.Line++
+		skipped.Column = 0
+	} else {
+		skipped.Column++
+	}
+
+	return skipped
+}
+
+func leadingSpace(s string) morse.Position {
+	var skipped morse.Position
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			break
+		}
+		skipped = skip(skipped, r, utf8.RuneLen(r))
 	}
-	if isMorseLike(trimmed) {
-		return morse.ToText(trimmed), nil
+
+	return skipped
+}
+
+func shiftReport(report morse.ConversionReport, skipped morse.Position) morse.ConversionReport {
+	for i, issue := range report.Issues {
+		if issue.Line == 1 {
+			issue.Column += skipped.Column
+		}
+		issue.Line += skipped.Line
+		issue.Offset += skipped.Offset
+		report.Issues[i] = issue
 	}
-	return morse.ToMorse(trimmed), nil
+
+	return report
 }
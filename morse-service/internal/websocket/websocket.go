@@ -0,0 +1,497 @@
+// Package websocket implements the parts of the WebSocket protocol (RFC
+// 6455) the service needs: the handshake on both sides, text and binary
+// messages, fragmentation, ping and pong, and the closing handshake.
+// Extensions and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// Status codes of close frames.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// DefaultMaxMessageSize limits received messages unless a Conn is given
+// another limit.
+const DefaultMaxMessageSize = 64 << 10
+
+// DefaultWriteTimeout bounds every frame written unless a Conn is given
+// another timeout.
+const DefaultWriteTimeout = 10 * time.Second
+
+const maxControlPayload = 125
+
+// acceptGUID is appended to the client key to prove the server speaks
+// WebSocket.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	ErrBadHandshake = errors.New("bad websocket handshake")
+	ErrClosed       = errors.New("websocket closed")
+)
+
+// CloseError is returned by ReadMessage when the peer closes the
+// connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e CloseError) Error() string {
+	return fmt.Sprintf("websocket closed with status %d %s", e.Code, e.Reason)
+}
+
+// protocolError closes the connection with code; the peer broke the
+// protocol or a limit.
+type protocolError struct {
+	code int
+	msg  string
+}
+
+func (e protocolError) Error() string {
+	return "websocket: " + e.msg
+}
+
+// Conn is a WebSocket connection. Reads must come from a single goroutine;
+// writes may come from any.
+type Conn struct {
+	// MaxMessageSize limits the size of received messages; larger ones
+	// close the connection with CloseMessageTooBig.
+	MaxMessageSize int64
+	// WriteTimeout bounds the write of a frame, so that a peer that
+	// stops reading does not block the writer; 0 means no timeout.
+	WriteTimeout time.Duration
+
+	conn   net.Conn
+	r      *bufio.Reader
+	client bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newConn(conn net.Conn, r *bufio.Reader, client bool) *Conn {
+	return &Conn{
+		MaxMessageSize: DefaultMaxMessageSize,
+		WriteTimeout:   DefaultWriteTimeout,
+		conn:           conn,
+		r:              r,
+		client:         client,
+	}
+}
+
+// Upgrade answers a WebSocket handshake and takes over the connection of
+// the request. On failure it writes the error response itself. Requests
+// from pages of another origin are refused, as the browser would send
+// them along with the user's cookies.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	fail := func(status int, msg string) (*Conn, error) {
+		if status == http.StatusUpgradeRequired {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+		}
+		http.Error(w, msg, status)
+		return nil, fmt.Errorf("%w: %s", ErrBadHandshake, msg)
+	}
+
+	switch {
+	case r.Method != http.MethodGet:
+		return fail(http.StatusMethodNotAllowed, "method not allowed")
+	case !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket"):
+		return fail(http.StatusUpgradeRequired, "websocket upgrade required")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	case !sameOrigin(r):
+		return fail(http.StatusForbidden, "cross-origin websocket request")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "connection cannot be taken over")
+	}
+	// The deadlines of the HTTP server are meant for the request.
+	_ = conn.SetDeadline(time.Time{})
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := brw.WriteString(resp); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return newConn(conn, brw.Reader, false), nil
+}
+
+// Dial opens a client connection to a ws:// URL.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	var nonce [16]byte
+	_, _ = rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header.Clone(),
+		Host:       u.Host,
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrBadHandshake, resp.Status)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return newConn(conn, r, true), nil
+}
+
+// ReadMessage returns the next data message. Pings are answered on the
+// way. When the peer closes the connection, the close is echoed and a
+// CloseError returned; when the peer breaks the protocol, the connection
+// is closed with the matching status.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		typ     MessageType
+		message []byte
+	)
+
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.answerClose(payload)
+		case opContinuation:
+			if typ == 0 {
+				return 0, nil, c.fail(protocolError{CloseProtocolError, "continuation without a message"})
+			}
+		default:
+			if typ != 0 {
+				return 0, nil, c.fail(protocolError{CloseProtocolError, "message interrupted by another"})
+			}
+			typ = MessageType(op)
+		}
+
+		if int64(len(message))+int64(len(payload)) > c.MaxMessageSize {
+			return 0, nil, c.fail(protocolError{CloseMessageTooBig, "message too big"})
+		}
+		message = append(message, payload...)
+
+		if !fin {
+			continue
+		}
+
+		if typ == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(protocolError{CloseInvalidPayload, "text message is not UTF-8"})
+		}
+
+		return typ, message, nil
+	}
+}
+
+func (c *Conn) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := head[0]&0x80 != 0
+	op := head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+	size := uint64(head[1] & 0x7f)
+
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, protocolError{CloseProtocolError, "reserved bits set"}
+	}
+	switch op {
+	case opContinuation, opText, opBinary, opClose, opPing, opPong:
+	default:
+		return false, 0, nil, protocolError{CloseProtocolError, fmt.Sprintf("unknown opcode %#x", op)}
+	}
+	if masked == c.client {
+		return false, 0, nil, protocolError{CloseProtocolError, "wrong masking"}
+	}
+
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if op >= opClose && (!fin || size > maxControlPayload) {
+		return false, 0, nil, protocolError{CloseProtocolError, "invalid control frame"}
+	}
+	if op < opClose && size > uint64(c.MaxMessageSize) {
+		return false, 0, nil, protocolError{CloseMessageTooBig, "message too big"}
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		maskBytes(payload, mask)
+	}
+
+	return fin, op, payload, nil
+}
+
+// answerClose echoes the close frame of the peer and closes the
+// connection.
+func (c *Conn) answerClose(payload []byte) error {
+	e := CloseError{Code: CloseNoStatus}
+	if len(payload) >= 2 {
+		e.Code = int(binary.BigEndian.Uint16(payload))
+		e.Reason = string(payload[2:])
+	}
+
+	_ = c.writeFrame(opClose, payload[:min(len(payload), 2)])
+	c.conn.Close()
+
+	return e
+}
+
+// fail closes the connection after a read error, telling the peer why if
+// it broke the protocol.
+func (c *Conn) fail(err error) error {
+	var perr protocolError
+	if errors.As(err, &perr) {
+		_ = c.Close(perr.code, perr.msg)
+		return err
+	}
+
+	c.conn.Close()
+	return err
+}
+
+// WriteMessage sends a data message in a single frame.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	return c.writeFrame(byte(typ), data)
+}
+
+// Close sends a close frame with the status code and reason and closes
+// the connection without waiting for the peer's answer.
+func (c *Conn) Close(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason[:min(len(reason), maxControlPayload-2)]...)
+
+	err := c.writeFrame(opClose, payload)
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	if errors.Is(err, ErrClosed) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// SetReadDeadline bounds the wait for the next frame.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	if op == opClose {
+		c.closed = true
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|op)
+
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	if !c.client {
+		frame = append(frame, payload...)
+	} else {
+		var mask [4]byte
+		_, _ = rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(frame[start:], mask)
+	}
+
+	if c.WriteTimeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(b []byte, mask [4]byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHas reports whether a comma separated header lists token.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// sameOrigin accepts requests from pages of the same host and those
+// without an Origin, which do not come from browsers.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,224 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echo starts a server that sends every message back and returns its
+// ws:// URL and the error its last connection ended with.
+func echo(t *testing.T) (string, <-chan error) {
+	t.Helper()
+
+	errc := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		c.MaxMessageSize = 16
+
+		for {
+			typ, msg, err := c.ReadMessage()
+			if err != nil {
+				errc <- err
+				return
+			}
+			if err := c.WriteMessage(typ, msg); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), errc
+}
+
+func dial(t *testing.T, url string) *Conn {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial(ctx, url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close(CloseNormal, "") })
+
+	return c
+}
+
+// writeRaw sends a frame as is, to break the protocol on purpose.
+func writeRaw(t *testing.T, c *Conn, first byte, payload []byte) {
+	t.Helper()
+
+	frame := []byte{first, 0x80 | byte(len(payload)), 0, 0, 0, 0}
+	_, err := c.conn.Write(append(frame, payload...))
+	require.NoError(t, err)
+}
+
+func TestEcho(t *testing.T) {
+	url, _ := echo(t)
+	c := dial(t, url)
+
+	require.NoError(t, c.WriteMessage(TextMessage, []byte("привет")))
+	typ, msg, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, TextMessage, typ)
+	assert.Equal(t, "привет", string(msg))
+
+	require.NoError(t, c.WriteMessage(BinaryMessage, []byte{0, 1, 2}))
+	typ, msg, err = c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, BinaryMessage, typ)
+	assert.Equal(t, []byte{0, 1, 2}, msg)
+}
+
+func TestFragmentsAndPing(t *testing.T) {
+	url, _ := echo(t)
+	c := dial(t, url)
+
+	writeRaw(t, c, opText, []byte("ab"))
+	writeRaw(t, c, 0x80|opPing, []byte("ping"))
+	writeRaw(t, c, 0x80|opContinuation, []byte("cd"))
+
+	// The pong comes first and is skipped by the reader.
+	typ, msg, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, TextMessage, typ)
+	assert.Equal(t, "abcd", string(msg))
+}
+
+func TestClose(t *testing.T) {
+	url, errc := echo(t)
+	c := dial(t, url)
+
+	require.NoError(t, c.Close(CloseGoingAway, "bye"))
+	assert.Equal(t, CloseError{Code: CloseGoingAway, Reason: "bye"}, <-errc)
+}
+
+func TestProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		first byte
+		data  []byte
+		code  int
+	}{
+		{"too big", 0x80 | opText, []byte(strings.Repeat("x", 17)), CloseMessageTooBig},
+		{"invalid UTF-8", 0x80 | opText, []byte{0xff}, CloseInvalidPayload},
+		{"reserved bits", 0xc0 | opText, nil, CloseProtocolError},
+		{"unknown opcode", 0x80 | 0x3, nil, CloseProtocolError},
+		{"lone continuation", 0x80 | opContinuation, nil, CloseProtocolError},
+		{"fragmented ping", opPing, nil, CloseProtocolError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, errc := echo(t)
+			c := dial(t, url)
+
+			writeRaw(t, c, tt.first, tt.data)
+
+			_, _, err := c.ReadMessage()
+			var closed CloseError
+			require.ErrorAs(t, err, &closed)
+			assert.Equal(t, tt.code, closed.Code)
+			assert.Error(t, <-errc)
+		})
+	}
+}
+
+func TestUnmaskedClientFrame(t *testing.T) {
+	url, errc := echo(t)
+	c := dial(t, url)
+
+	c.client = false
+	require.NoError(t, c.WriteMessage(TextMessage, []byte("x")))
+	assert.ErrorContains(t, <-errc, "wrong masking")
+}
+
+func TestHandshakeErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := Upgrade(w, r)
+		assert.ErrorIs(t, err, ErrBadHandshake)
+	}))
+	defer srv.Close()
+
+	upgrade := http.Header{
+		"Connection":            {"keep-alive, Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+	}
+	with := func(key, value string) http.Header {
+		h := upgrade.Clone()
+		h.Set(key, value)
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		status int
+	}{
+		{"plain request", http.MethodGet, nil, http.StatusUpgradeRequired},
+		{"post", http.MethodPost, upgrade, http.StatusMethodNotAllowed},
+		{"old version", http.MethodGet, with("Sec-WebSocket-Version", "8"), http.StatusUpgradeRequired},
+		{"bad key", http.MethodGet, with("Sec-WebSocket-Key", "short"), http.StatusBadRequest},
+		{"other origin", http.MethodGet, with("Origin", "http://evil.example"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL, nil)
+			require.NoError(t, err)
+			req.Header = tt.header
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example of RFC 6455, section 1.3.
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestCloseReasonTruncated(t *testing.T) {
+	url, errc := echo(t)
+	c := dial(t, url)
+
+	require.NoError(t, c.Close(CloseNormal, strings.Repeat("x", 200)))
+
+	var closed CloseError
+	require.ErrorAs(t, <-errc, &closed)
+	assert.Len(t, closed.Reason, maxControlPayload-2)
+	assert.Equal(t, CloseNormal, closed.Code)
+}
+
+func TestWriteTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	// Nobody reads from client, so the write blocks until the deadline.
+	c := newConn(server, bufio.NewReader(server), false)
+	c.WriteTimeout = 20 * time.Millisecond
+
+	err := c.WriteMessage(TextMessage, []byte("hello"))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
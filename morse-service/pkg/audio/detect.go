@@ -0,0 +1,153 @@
+package audio
+
+import (
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	"sprint6/pkg/morse"
+)
+
+// DetectOptions control tone detection. With a zero Frequency the signal
+// envelope is used, which works for any pitch but is more sensitive to noise;
+// otherwise a Goertzel filter tuned to Frequency measures the tone level.
+type DetectOptions struct {
+	Frequency float64
+	Window    time.Duration
+}
+
+var DefaultDetectOptions = DetectOptions{Window: 5 * time.Millisecond}
+
+// minContrast is the smallest ratio between the loudest block and the noise
+// floor that is still treated as a keyed signal.
+const minContrast = 4
+
+// Detect reads a PCM WAV file and returns the key-down and key-up periods of
+// the Morse signal in it. Leading and trailing silence is dropped.
+func Detect(r io.Reader, opts DetectOptions) ([]morse.Element, error) {
+	wr, err := NewWAVReader(r)
+	if err != nil {
+		return nil, err
+	}
+	f := wr.Format
+
+	if opts.Window <= 0 {
+		opts.Window = DefaultDetectOptions.Window
+	}
+	block := max(1, int(opts.Window.Seconds()*float64(f.SampleRate)))
+
+	// The samples are read a block at a time; only one level per block is
+	// kept.
+	var levels []float64
+	chunk := make([]float64, block)
+	for {
+		n, err := readBlock(wr, chunk)
+		if n > 0 {
+			if opts.Frequency > 0 {
+				levels = append(levels, goertzel(chunk[:n], opts.Frequency, f.SampleRate))
+			} else {
+				levels = append(levels, rms(chunk[:n]))
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keyed := threshold(levels)
+	if keyed == nil {
+		return nil, nil
+	}
+
+	blockDur := time.Duration(float64(block) / float64(f.SampleRate) * float64(time.Second))
+
+	var elements []morse.Element
+	for i := 0; i < len(keyed); {
+		j := i
+		for j < len(keyed) && keyed[j] == keyed[i] {
+			j++
+		}
+
+		if keyed[i] || len(elements) != 0 {
+			elements = append(elements, morse.Element{On: keyed[i], Dur: time.Duration(j-i) * blockDur})
+		}
+		i = j
+	}
+
+	if n := len(elements); n != 0 && !elements[n-1].On {
+		elements = elements[:n-1]
+	}
+
+	return elements, nil
+}
+
+// readBlock fills block from wr unless the samples run out first.
+func readBlock(wr *WAVReader, block []float64) (int, error) {
+	var n int
+	for n < len(block) {
+		m, err := wr.Read(block[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// threshold marks every block as keyed or not using hysteresis between the
+// noise floor and the peak level. It returns nil if there is no signal.
+func threshold(levels []float64) []bool {
+	if len(levels) == 0 {
+		return nil
+	}
+
+	sorted := slices.Sorted(slices.Values(levels))
+	floor := sorted[len(sorted)/10]
+	peak := sorted[len(sorted)-1-len(sorted)/100]
+	if peak <= 0 || peak < floor*minContrast {
+		return nil
+	}
+
+	high := floor + 0.6*(peak-floor)
+	low := floor + 0.4*(peak-floor)
+
+	keyed := make([]bool, len(levels))
+	on := false
+	for i, l := range levels {
+		switch {
+		case !on && l >= high:
+			on = true
+		case on && l < low:
+			on = false
+		}
+		keyed[i] = on
+	}
+
+	return keyed
+}
+
+func goertzel(samples []float64, freq float64, rate int) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/float64(rate))
+
+	var s1, s2 float64
+	for _, x := range samples {
+		s1, s2 = x+coeff*s1-s2, s1
+	}
+
+	power := s1*s1 + s2*s2 - coeff*s1*s2
+	return math.Sqrt(max(power, 0)) / float64(len(samples))
+}
+
+func rms(samples []float64) float64 {
+	var sum float64
+	for _, x := range samples {
+		sum += x * x
+	}
+
+	return math.Sqrt(sum / float64(len(samples)))
+}
@@ -0,0 +1,168 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/pkg/morse"
+)
+
+func render(t *testing.T, text string, wpm, farnsworth float64, tone Tone) []byte {
+	t.Helper()
+
+	timing, err := morse.NewTiming(wpm, farnsworth)
+	require.NoError(t, err)
+
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = morse.ToMorse(w)
+	}
+	code := strings.Join(words, "   ")
+
+	var buf bytes.Buffer
+	require.NoError(t, tone.WriteWAV(&buf, morse.DefaultConverter.Elements(code, timing)))
+
+	return buf.Bytes()
+}
+
+func TestDetectRoundTrip(t *testing.T) {
+	const text = "ПРИВЕТ МИР 73"
+
+	tests := []struct {
+		name       string
+		wpm        float64
+		farnsworth float64
+		tone       Tone
+		opts       DetectOptions
+	}{
+		{"envelope", 20, 0, DefaultTone, DefaultDetectOptions},
+		{"goertzel", 20, 0, DefaultTone, DetectOptions{Frequency: DefaultTone.Frequency}},
+		{"slow", 8, 0, DefaultTone, DefaultDetectOptions},
+		{"fast", 35, 0, Tone{Frequency: 800, SampleRate: 44100, Volume: 0.5}, DefaultDetectOptions},
+		{"farnsworth", 18, 6, DefaultTone, DefaultDetectOptions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wav := render(t, text, tt.wpm, tt.farnsworth, tt.tone)
+
+			elements, err := Detect(bytes.NewReader(wav), tt.opts)
+			require.NoError(t, err)
+
+			code, wpm := morse.DefaultConverter.FromElements(elements)
+			assert.Equal(t, text, morse.ToText(code))
+			assert.InDelta(t, tt.wpm, wpm, tt.wpm*0.1)
+		})
+	}
+}
+
+func TestDetectNoise(t *testing.T) {
+	wav := render(t, "СОС", 15, 0, Tone{Frequency: 700, SampleRate: 8000, Volume: 0.3})
+
+	// Add white noise as loud as the signal itself: the tuned filter still
+	// separates the tone from it.
+	rnd := rand.New(rand.NewSource(1))
+	for i := wavHeaderSize; i+1 < len(wav); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(wav[i:]))) + rnd.NormFloat64()*0.3*32767/3
+		binary.LittleEndian.PutUint16(wav[i:], uint16(int16(max(-32768, min(32767, v)))))
+	}
+
+	elements, err := Detect(bytes.NewReader(wav), DetectOptions{Frequency: 700})
+	require.NoError(t, err)
+
+	code, _ := morse.DefaultConverter.FromElements(elements)
+	assert.Equal(t, "СОС", morse.ToText(code))
+}
+
+func TestDetectSilence(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, DefaultTone.WriteWAV(&buf, []morse.Element{{On: false, Dur: 1e9}}))
+
+	elements, err := Detect(&buf, DefaultDetectOptions)
+	require.NoError(t, err)
+	assert.Empty(t, elements)
+}
+
+// wavFile builds a WAV file from a fmt chunk body and PCM data.
+func wavFile(fmtBody, data []byte) []byte {
+	var b []byte
+	b = append(b, "RIFF\x00\x00\x00\x00WAVE"...)
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(fmtBody)))
+	b = append(b, fmtBody...)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+func fmtChunk(channels, rate, bits int, extra int) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint16(b, pcmFormat)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate*channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits))
+	return append(b, make([]byte, extra)...)
+}
+
+func TestReadWAV(t *testing.T) {
+	// Two channels of 16-bit samples, with the 2-byte extension of the fmt
+	// chunk and a trailing half frame.
+	data := []byte{0x00, 0x40, 0x00, 0x40, 0x00, 0xc0, 0x00, 0x00, 0xff}
+
+	f, samples, err := ReadWAV(iotest.OneByteReader(bytes.NewReader(wavFile(fmtChunk(2, 8000, 16, 2), data))))
+	require.NoError(t, err)
+	assert.Equal(t, Format{SampleRate: 8000, Channels: 2, BitsPerSample: 16}, f)
+	assert.Equal(t, []float64{0.5, -0.25}, samples)
+}
+
+func TestWAVReader(t *testing.T) {
+	wav := render(t, "СОС", 20, 0, DefaultTone)
+	_, want, err := ReadWAV(bytes.NewReader(wav))
+	require.NoError(t, err)
+
+	wr, err := NewWAVReader(bytes.NewReader(wav))
+	require.NoError(t, err)
+
+	var got []float64
+	block := make([]float64, 7)
+	for {
+		n, err := wr.Read(block)
+		got = append(got, block[:n]...)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestReadWAVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not a wav file", []byte("not a wav file at all")},
+		{"huge fmt chunk", append(wavFile(nil, nil)[:16], 0xff, 0xff, 0xff, 0x7f)},
+		{"long fmt chunk", wavFile(fmtChunk(1, 8000, 16, 26), nil)},
+		{"short fmt chunk", wavFile(fmtChunk(1, 8000, 16, 0)[:14], nil)},
+		{"too many channels", wavFile(fmtChunk(1000, 8000, 16, 0), nil)},
+		{"sample rate", wavFile(fmtChunk(1, 1<<30, 16, 0), nil)},
+		{"bits", wavFile(fmtChunk(1, 8000, 12, 0), nil)},
+		{"no data chunk", wavFile(fmtChunk(1, 8000, 16, 0), nil)[:36]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ReadWAV(bytes.NewReader(tt.data))
+			require.ErrorIs(t, err, ErrUnsupportedWAV)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"slices"
+	"time"
+
+	"sprint6/pkg/morse"
+)
+
+var (
+	ErrInvalidTone = errors.New("invalid tone")
+	ErrTooLong     = errors.New("audio is too long for a WAV file")
+)
+
+// Tone renders Morse elements as a sine wave keyed on and off. Rise is the
+// length of the raised-cosine ramp at both edges of a mark, which avoids
+// audible clicks.
+type Tone struct {
+	Frequency  float64
+	SampleRate int
+	Rise       time.Duration
+	Volume     float64
+}
+
+var DefaultTone = Tone{
+	Frequency:  600,
+	SampleRate: 8000,
+	Rise:       5 * time.Millisecond,
+	Volume:     0.8,
+}
+
+const (
+	bitsPerSample = 16
+	// MaxSampleRate is the highest sample rate a tone is rendered at.
+	MaxSampleRate = 48000
+)
+
+func (t Tone) Validate() error {
+	switch {
+	case t.SampleRate <= 0 || t.SampleRate > MaxSampleRate:
+		return fmt.Errorf("%w: sample rate %d Hz", ErrInvalidTone, t.SampleRate)
+	case t.Frequency <= 0 || t.Frequency >= float64(t.SampleRate)/2:
+		return fmt.Errorf("%w: frequency %v Hz at sample rate %d Hz", ErrInvalidTone, t.Frequency, t.SampleRate)
+	case t.Rise < 0:
+		return fmt.Errorf("%w: rise time %v", ErrInvalidTone, t.Rise)
+	case t.Volume <= 0 || t.Volume > 1:
+		return fmt.Errorf("%w: volume %v", ErrInvalidTone, t.Volume)
+	}
+
+	return nil
+}
+
+// WriteWAV writes the elements as a mono 16-bit PCM WAV file.
+func (t Tone) WriteWAV(w io.Writer, elements []morse.Element) error {
+	return t.WriteSignal(w, slices.Values(elements))
+}
+
+// Size returns the size of the WAV file of a signal lasting d.
+func (t Tone) Size(d time.Duration) (int64, error) {
+	size := int64(t.samples(d)) * int64(t.format().blockAlign())
+	if size > math.MaxUint32-wavHeaderSize {
+		return 0, ErrTooLong
+	}
+
+	return wavHeaderSize + size, nil
+}
+
+// WriteSignal is like WriteWAV, but renders the elements as signal yields
+// them. The signal is iterated twice: once for the length the header
+// starts with and once for the samples.
+func (t Tone) WriteSignal(w io.Writer, signal iter.Seq[morse.Element]) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	var total time.Duration
+	for e := range signal {
+		total += e.Dur
+	}
+	size, err := t.Size(total)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := WriteHeader(bw, t.format(), uint32(size-wavHeaderSize)); err != nil {
+		return err
+	}
+
+	ramp := t.samples(t.Rise)
+	step := 2 * math.Pi * t.Frequency / float64(t.SampleRate)
+
+	var (
+		elapsed time.Duration
+		pos     int
+		buf     [2]byte
+	)
+
+	for e := range signal {
+		// Sample positions are derived from the running time, so rounding
+		// errors do not accumulate over long messages.
+		elapsed += e.Dur
+		end := t.samples(elapsed)
+		n := end - pos
+
+		for i := range n {
+			var v float64
+			if e.On {
+				v = t.Volume * envelope(i, n, ramp) * math.Sin(step*float64(pos+i))
+			}
+
+			binary.LittleEndian.PutUint16(buf[:], uint16(int16(math.Round(v*math.MaxInt16))))
+			if _, err := bw.Write(buf[:]); err != nil {
+				return err
+			}
+		}
+
+		pos = end
+	}
+
+	return bw.Flush()
+}
+
+func (t Tone) format() Format {
+	return Format{SampleRate: t.SampleRate, Channels: 1, BitsPerSample: bitsPerSample}
+}
+
+func (t Tone) samples(d time.Duration) int {
+	return int(math.Round(d.Seconds() * float64(t.SampleRate)))
+}
+
+func envelope(i, n, ramp int) float64 {
+	ramp = min(ramp, n/2)
+
+	switch {
+	case i < ramp:
+		return 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(ramp))
+	case i >= n-ramp:
+		return 0.5 - 0.5*math.Cos(math.Pi*float64(n-1-i)/float64(ramp))
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,62 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sprint6/pkg/morse"
+)
+
+func TestWriteWAV(t *testing.T) {
+	elements := []morse.Element{
+		{On: true, Dur: 60 * time.Millisecond},
+		{On: false, Dur: 60 * time.Millisecond},
+		{On: true, Dur: 180 * time.Millisecond},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, DefaultTone.WriteWAV(&buf, elements))
+
+	data := buf.Bytes()
+	require.Greater(t, len(data), wavHeaderSize)
+	assert.Equal(t, "RIFF", string(data[0:4]))
+	assert.Equal(t, "WAVE", string(data[8:12]))
+	assert.Equal(t, uint32(DefaultTone.SampleRate), binary.LittleEndian.Uint32(data[24:]))
+
+	// 300 ms at 8 kHz, two bytes per sample.
+	assert.Equal(t, uint32(2400*2), binary.LittleEndian.Uint32(data[40:]))
+	assert.Len(t, data, wavHeaderSize+2400*2)
+
+	// The envelope starts and ends every mark at silence, and the gap is silent.
+	sample := func(i int) int16 { return int16(binary.LittleEndian.Uint16(data[wavHeaderSize+2*i:])) }
+	assert.Zero(t, sample(0))
+	assert.Zero(t, sample(479))
+	for i := 480; i < 960; i++ {
+		require.Zero(t, sample(i))
+	}
+}
+
+func TestToneValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Tone)
+	}{
+		{"rate", func(t *Tone) { t.SampleRate = 0 }},
+		{"high rate", func(t *Tone) { t.SampleRate = 96000 }},
+		{"nyquist", func(t *Tone) { t.Frequency = 4000 }},
+		{"rise", func(t *Tone) { t.Rise = -time.Millisecond }},
+		{"volume", func(t *Tone) { t.Volume = 2 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tone := DefaultTone
+			tt.modify(&tone)
+			require.ErrorIs(t, tone.Validate(), ErrInvalidTone)
+		})
+	}
+}
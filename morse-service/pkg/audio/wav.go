@@ -0,0 +1,214 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Format describes linear PCM samples stored in a WAV file.
+type Format struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+}
+
+const (
+	wavHeaderSize = 44
+	pcmFormat     = 1
+)
+
+func (f Format) blockAlign() int {
+	return f.Channels * f.BitsPerSample / 8
+}
+
+// WriteHeader writes a canonical 44-byte WAV header for dataSize bytes of
+// PCM data that follow it.
+func WriteHeader(w io.Writer, f Format, dataSize uint32) error {
+	var h [wavHeaderSize]byte
+
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], wavHeaderSize-8+dataSize)
+	copy(h[8:], "WAVE")
+
+	copy(h[12:], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], pcmFormat)
+	binary.LittleEndian.PutUint16(h[22:], uint16(f.Channels))
+	binary.LittleEndian.PutUint32(h[24:], uint32(f.SampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(f.SampleRate*f.blockAlign()))
+	binary.LittleEndian.PutUint16(h[32:], uint16(f.blockAlign()))
+	binary.LittleEndian.PutUint16(h[34:], uint16(f.BitsPerSample))
+
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], dataSize)
+
+	_, err := w.Write(h[:])
+	return err
+}
+
+var ErrUnsupportedWAV = errors.New("unsupported WAV file")
+
+// Limits on what a WAV file may declare, as they size the buffers it is
+// read with. maxFmtSize is the size of the largest fmt chunk, that of
+// WAVE_FORMAT_EXTENSIBLE.
+const (
+	maxFmtSize    = 40
+	maxChannels   = 8
+	maxSampleRate = 192000
+)
+
+// WAVReader reads the samples of a PCM WAV file as they are needed, scaled
+// to [-1, 1]. Multi-channel files are mixed down to mono.
+type WAVReader struct {
+	Format Format
+
+	data io.Reader
+	buf  []byte
+	err  error
+}
+
+// NewWAVReader reads the headers of a PCM WAV file up to its data chunk.
+func NewWAVReader(r io.Reader) (*WAVReader, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedWAV, err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%w: not a RIFF/WAVE file", ErrUnsupportedWAV)
+	}
+
+	var (
+		f      Format
+		hasFmt bool
+	)
+
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("%w: no data chunk", ErrUnsupportedWAV)
+		}
+		id, size := string(chunk[0:4]), int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 || size > maxFmtSize {
+				return nil, fmt.Errorf("%w: fmt chunk of %d bytes", ErrUnsupportedWAV, size)
+			}
+
+			var body [maxFmtSize + 1]byte
+			if _, err := io.ReadFull(r, body[:size+size%2]); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrUnsupportedWAV, err)
+			}
+
+			if tag := binary.LittleEndian.Uint16(body[0:]); tag != pcmFormat {
+				return nil, fmt.Errorf("%w: format tag %d is not PCM", ErrUnsupportedWAV, tag)
+			}
+			f = Format{
+				Channels:      int(binary.LittleEndian.Uint16(body[2:])),
+				SampleRate:    int(binary.LittleEndian.Uint32(body[4:])),
+				BitsPerSample: int(binary.LittleEndian.Uint16(body[14:])),
+			}
+			if f.Channels == 0 || f.Channels > maxChannels ||
+				f.SampleRate == 0 || f.SampleRate > maxSampleRate ||
+				f.BitsPerSample%8 != 0 || f.BitsPerSample == 0 || f.BitsPerSample > 32 {
+				return nil, fmt.Errorf("%w: %+v", ErrUnsupportedWAV, f)
+			}
+			hasFmt = true
+		case "data":
+			if !hasFmt {
+				return nil, fmt.Errorf("%w: data before fmt chunk", ErrUnsupportedWAV)
+			}
+
+			return &WAVReader{Format: f, data: io.LimitReader(r, size)}, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrUnsupportedWAV, err)
+			}
+		}
+	}
+}
+
+// Read fills dst with the next samples and returns how many it read. At the
+// end of the data it returns io.EOF. A data chunk cut short, as by a
+// truncated upload, ends early without an error.
+func (wr *WAVReader) Read(dst []float64) (int, error) {
+	if wr.err != nil {
+		return 0, wr.err
+	}
+
+	size := len(dst) * wr.Format.blockAlign()
+	if cap(wr.buf) < size {
+		wr.buf = make([]byte, size)
+	}
+	buf := wr.buf[:size]
+
+	n, err := io.ReadFull(wr.data, buf)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		wr.err = io.EOF
+	case err != nil:
+		wr.err = err
+		return 0, err
+	}
+
+	frames := n / wr.Format.blockAlign()
+	if frames == 0 {
+		return 0, wr.err
+	}
+	wr.Format.samples(dst[:frames], buf[:frames*wr.Format.blockAlign()])
+
+	return frames, nil
+}
+
+// ReadWAV reads a whole PCM WAV file and returns its samples scaled to
+// [-1, 1]. Multi-channel files are mixed down to mono.
+func ReadWAV(r io.Reader) (Format, []float64, error) {
+	wr, err := NewWAVReader(r)
+	if err != nil {
+		return Format{}, nil, err
+	}
+
+	var (
+		samples []float64
+		block   [4096]float64
+	)
+	for {
+		n, err := wr.Read(block[:])
+		samples = append(samples, block[:n]...)
+		if err == io.EOF {
+			return wr.Format, samples, nil
+		}
+		if err != nil {
+			return Format{}, nil, err
+		}
+	}
+}
+
+// samples decodes the frames of data into out, one mono sample per frame.
+func (f Format) samples(out []float64, data []byte) {
+	width := f.BitsPerSample / 8
+	scale := float64(int64(1) << (f.BitsPerSample - 1))
+
+	for i := range out {
+		var sum float64
+		for ch := range f.Channels {
+			b := data[(i*f.Channels+ch)*width:]
+
+			var v int64
+			if width == 1 {
+				// 8-bit PCM is unsigned.
+				v = int64(b[0]) - 128
+			} else {
+				for j := width - 1; j >= 0; j-- {
+					v = v<<8 | int64(b[j])
+				}
+				v = v << (64 - f.BitsPerSample) >> (64 - f.BitsPerSample)
+			}
+
+			sum += float64(v) / scale
+		}
+		out[i] = sum / float64(f.Channels)
+	}
+}
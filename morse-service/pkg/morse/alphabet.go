@@ -0,0 +1,194 @@
+package morse
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Digits and ITU punctuation shared by the international alphabets.
+var (
+	ituDigits = EncodingMap{
+		'1': One,
+		'2': Two,
+		'3': Three,
+		'4': Four,
+		'5': Five,
+		'6': Six,
+		'7': Seven,
+		'8': Eight,
+		'9': Nine,
+		'0': Zero,
+	}
+
+	ituPunctuation = EncodingMap{
+		'.':  ".-.-.-",
+		',':  "--..--",
+		':':  Colon,
+		'?':  QuestionMark,
+		'\'': Apostrophe,
+		'-':  Hyphen,
+		'/':  Division,
+		'(':  LeftBracket,
+		')':  RightBracket,
+		'"':  IvertedComma,
+		'=':  DoubleHyphen,
+		'+':  Cross,
+		'@':  CommercialAt,
+	}
+)
+
+// LatinMorse is the International Morse code as defined by ITU-R M.1677-1.
+var LatinMorse = merge(EncodingMap{
+	'A': ".-",
+	'B': "-...",
+	'C': "-.-.",
+	'D': "-..",
+	'E': ".",
+	'F': "..-.",
+	'G': "--.",
+	'H': "....",
+	'I': "..",
+	'J': ".---",
+	'K': "-.-",
+	'L': ".-..",
+	'M': "--",
+	'N': "-.",
+	'O': "---",
+	'P': ".--.",
+	'Q': "--.-",
+	'R': ".-.",
+	'S': "...",
+	'T': "-",
+	'U': "..-",
+	'V': "...-",
+	'W': ".--",
+	'X': "-..-",
+	'Y': "-.--",
+	'Z': "--..",
+}, ituDigits, ituPunctuation)
+
+// GermanMorse extends LatinMorse with umlauts and sharp s.
+var GermanMorse = merge(LatinMorse, EncodingMap{
+	'Ä': ".-.-",
+	'Ö': "---.",
+	'Ü': "..--",
+	'ß': "...--..",
+})
+
+var GreekMorse = merge(EncodingMap{
+	'Α': ".-",
+	'Β': "-...",
+	'Γ': "--.",
+	'Δ': "-..",
+	'Ε': ".",
+	'Ζ': "--..",
+	'Η': "....",
+	'Θ': "-.-.",
+	'Ι': "..",
+	'Κ': "-.-",
+	'Λ': ".-..",
+	'Μ': "--",
+	'Ν': "-.",
+	'Ξ': "-..-",
+	'Ο': "---",
+	'Π': ".--.",
+	'Ρ': ".-.",
+	'Σ': "...",
+	'Τ': "-",
+	'Υ': "-.--",
+	'Φ': "..-.",
+	'Χ': "----",
+	'Ψ': "--.-",
+	'Ω': ".--",
+}, ituDigits, ituPunctuation)
+
+const DefaultAlphabet = "russian"
+
+var ErrUnknownAlphabet = errors.New("unknown alphabet")
+
+type registered struct {
+	encoding EncodingMap
+	options  []ConverterOption
+}
+
+var (
+	alphabetsMu sync.RWMutex
+	alphabets   = map[string]registered{}
+)
+
+func init() {
+	Register(DefaultAlphabet, DefaultMorse, WithDecoding(ЪЬ, 'Ь'), WithFrequencies(RussianFrequencies))
+	Register("latin", LatinMorse, WithFrequencies(LatinFrequencies))
+	Register("german", GermanMorse, WithFrequencies(GermanFrequencies))
+	Register("greek", GreekMorse, WithFrequencies(GreekFrequencies))
+	Register("russian-latin", DefaultMorse,
+		WithDecoding(ЪЬ, 'Ь'),
+		WithFrequencies(mergeFrequencies(RussianFrequencies, LatinFrequencies)),
+		WithShifts(ShiftRussian, Shift{Code: ShiftLatin, Encoding: LatinMorse}),
+	)
+}
+
+// Register makes an alphabet available by name. Names are case-insensitive.
+// The options are applied before the caller's ones by NewConverterFor.
+// It panics if the map is nil or the name is empty or already registered.
+func Register(name string, encoding EncodingMap, options ...ConverterOption) {
+	name = strings.ToLower(name)
+
+	if encoding == nil {
+		panic("morse: Register of a nil EncodingMap")
+	}
+	if name == "" {
+		panic("morse: Register with an empty name")
+	}
+
+	alphabetsMu.Lock()
+	defer alphabetsMu.Unlock()
+
+	if _, dup := alphabets[name]; dup {
+		panic("morse: Register called twice for alphabet " + name)
+	}
+	alphabets[name] = registered{encoding: encoding, options: options}
+}
+
+func Lookup(name string) (EncodingMap, bool) {
+	alphabetsMu.RLock()
+	defer alphabetsMu.RUnlock()
+
+	reg, ok := alphabets[strings.ToLower(name)]
+	return reg.encoding, ok
+}
+
+// Alphabets returns the sorted names of the registered alphabets.
+func Alphabets() []string {
+	alphabetsMu.RLock()
+	defer alphabetsMu.RUnlock()
+
+	return slices.Sorted(maps.Keys(alphabets))
+}
+
+func NewConverterFor(alphabet string, options ...ConverterOption) (Converter, error) {
+	alphabetsMu.RLock()
+	reg, ok := alphabets[strings.ToLower(alphabet)]
+	alphabetsMu.RUnlock()
+
+	if !ok {
+		return Converter{}, fmt.Errorf("%w: %q", ErrUnknownAlphabet, alphabet)
+	}
+
+	return NewConverter(reg.encoding, append(slices.Clip(reg.options), options...)...)
+}
+
+func merge(tables ...EncodingMap) EncodingMap {
+	ret := EncodingMap{}
+	for _, m := range tables {
+		for k, v := range m {
+			ret[k] = v
+		}
+	}
+
+	return ret
+}
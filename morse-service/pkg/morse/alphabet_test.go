@@ -0,0 +1,42 @@
+package morse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"russian", "Latin", "GERMAN", "greek"} {
+		_, ok := Lookup(name)
+		assert.True(t, ok, name)
+	}
+
+	_, ok := Lookup("klingon")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"german", "greek", "latin", "russian"}, Alphabets()[:4])
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-binary", EncodingMap{'0': ".", '1': "-"})
+
+	c, err := NewConverterFor("test-binary")
+	require.NoError(t, err)
+	assert.Equal(t, "- . -", c.ToMorse("101"))
+
+	assert.Panics(t, func() { Register("test-binary", EncodingMap{}) })
+	assert.Panics(t, func() { Register("", EncodingMap{}) })
+	assert.Panics(t, func() { Register("test-nil", nil) })
+}
+
+func TestNewConverterFor(t *testing.T) {
+	c, err := NewConverterFor("latin", WithLowercaseHandling(true))
+	require.NoError(t, err)
+	assert.Equal(t, ".... . .-.. .-.. ---", c.ToMorse("hello"))
+	assert.Equal(t, "SOS", c.ToText("... --- ..."))
+
+	_, err = NewConverterFor("klingon")
+	require.ErrorIs(t, err, ErrUnknownAlphabet)
+}
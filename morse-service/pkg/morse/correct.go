@@ -0,0 +1,221 @@
+package morse
+
+import (
+	"maps"
+	"math"
+	"slices"
+)
+
+// Frequencies are the relative letter frequencies of a language in
+// percent. They rank the candidates of a correction.
+type Frequencies map[rune]float64
+
+var (
+	RussianFrequencies = Frequencies{
+		'О': 10.97, 'Е': 8.45, 'А': 8.01, 'И': 7.35, 'Н': 6.70, 'Т': 6.26,
+		'С': 5.47, 'Р': 4.73, 'В': 4.54, 'Л': 4.40, 'К': 3.49, 'М': 3.21,
+		'Д': 2.98, 'П': 2.81, 'У': 2.62, 'Я': 2.01, 'Ы': 1.90, 'Ь': 1.74,
+		'Г': 1.70, 'З': 1.65, 'Б': 1.59, 'Ч': 1.44, 'Й': 1.21, 'Х': 0.97,
+		'Ж': 0.94, 'Ш': 0.73, 'Ю': 0.64, 'Ц': 0.48, 'Щ': 0.36, 'Э': 0.32,
+		'Ф': 0.26, 'Ъ': 0.04,
+	}
+
+	// LatinFrequencies are those of English.
+	LatinFrequencies = Frequencies{
+		'E': 12.70, 'T': 9.06, 'A': 8.17, 'O': 7.51, 'I': 6.97, 'N': 6.75,
+		'S': 6.33, 'H': 6.09, 'R': 5.99, 'D': 4.25, 'L': 4.03, 'C': 2.78,
+		'U': 2.76, 'M': 2.41, 'W': 2.36, 'F': 2.23, 'G': 2.02, 'Y': 1.97,
+		'P': 1.93, 'B': 1.29, 'V': 0.98, 'K': 0.77, 'J': 0.15, 'X': 0.15,
+		'Q': 0.10, 'Z': 0.07,
+	}
+
+	GermanFrequencies = Frequencies{
+		'E': 16.40, 'N': 9.78, 'S': 7.27, 'R': 7.00, 'I': 6.55, 'A': 6.52,
+		'T': 6.15, 'D': 5.08, 'H': 4.58, 'U': 4.17, 'L': 3.44, 'G': 3.01,
+		'C': 2.73, 'O': 2.59, 'M': 2.53, 'W': 1.92, 'B': 1.89, 'F': 1.66,
+		'K': 1.42, 'Z': 1.13, 'Ü': 0.99, 'V': 0.85, 'P': 0.67, 'Ä': 0.58,
+		'Ö': 0.44, 'ß': 0.31, 'J': 0.27, 'Y': 0.04, 'X': 0.03, 'Q': 0.02,
+	}
+
+	GreekFrequencies = Frequencies{
+		'Α': 12.00, 'Ο': 9.80, 'Ε': 8.00, 'Τ': 8.00, 'Ι': 7.80, 'Σ': 7.50,
+		'Ν': 6.60, 'Η': 5.00, 'Ρ': 4.60, 'Υ': 4.30, 'Π': 4.00, 'Κ': 4.00,
+		'Μ': 3.30, 'Λ': 2.70, 'Ω': 2.00, 'Δ': 1.80, 'Γ': 1.70, 'Θ': 1.30,
+		'Χ': 1.20, 'Φ': 0.90, 'Β': 0.80, 'Ξ': 0.50, 'Ζ': 0.50, 'Ψ': 0.15,
+	}
+)
+
+// minFrequency is assumed for runes missing from the frequencies, such as
+// digits and punctuation.
+const minFrequency = 0.01
+
+type correction struct {
+	maxDistance int
+	resegment   bool
+}
+
+func (c correction) enabled() bool {
+	return c.maxDistance > 0 || c.resegment
+}
+
+// WithFrequencies sets the letter frequencies used to rank corrections.
+func WithFrequencies(f Frequencies) ConverterOption {
+	return func(c Converter) Converter {
+		c.frequencies = f
+		return c
+	}
+}
+
+// WithCorrection makes the decoder fix codes it does not know instead of
+// passing them to the ErrorHandler. A code within maxDistance inserted,
+// deleted or replaced elements of a known one is decoded as that one; with
+// resegment, a code that is a run of known codes with the separators lost
+// is split. Fewer edits win, then more frequent letters. Every fix is
+// listed in the ConversionReport.
+func WithCorrection(maxDistance int, resegment bool) ConverterOption {
+	return func(c Converter) Converter {
+		c.correction = correction{maxDistance: max(maxDistance, 0), resegment: resegment}
+		return c
+	}
+}
+
+type candidate struct {
+	text  string
+	cost  int
+	score float64
+}
+
+func (c candidate) better(than candidate) bool {
+	if c.cost != than.cost {
+		return c.cost < than.cost
+	}
+	if c.score != than.score {
+		return c.score > than.score
+	}
+
+	return c.text < than.text
+}
+
+// correct returns the text the code most likely stands for in a, if any.
+func (c Converter) correct(code string, a alphabet) (string, bool) {
+	if !isCode(code) {
+		return "", false
+	}
+
+	best := candidate{cost: math.MaxInt}
+
+	if c.correction.maxDistance > 0 {
+		for known, r := range a.morseToRune {
+			if d := editDistance(code, known); d <= c.correction.maxDistance {
+				if cand := (candidate{text: string(r), cost: d, score: c.logFrequency(r)}); cand.better(best) {
+					best = cand
+				}
+			}
+		}
+	}
+
+	if c.correction.resegment {
+		if cand, ok := c.segment(code, a); ok && cand.better(best) {
+			best = cand
+		}
+	}
+
+	return best.text, best.cost != math.MaxInt
+}
+
+// segment splits code into the most likely run of known codes of a. The
+// cost is the number of separators put back, so at least two codes are
+// needed.
+func (c Converter) segment(code string, a alphabet) (candidate, bool) {
+	type step struct {
+		ok     bool
+		pieces int
+		score  float64
+		from   int
+		r      rune
+	}
+
+	steps := make([]step, len(code)+1)
+	steps[0].ok = true
+	for i := 1; i <= len(code); i++ {
+		for j := max(0, i-a.longest); j < i; j++ {
+			if !steps[j].ok {
+				continue
+			}
+			r, ok := a.morseToRune[code[j:i]]
+			if !ok {
+				continue
+			}
+
+			s := step{ok: true, pieces: steps[j].pieces + 1, score: steps[j].score + c.logFrequency(r), from: j, r: r}
+			if !steps[i].ok || s.score > steps[i].score {
+				steps[i] = s
+			}
+		}
+	}
+
+	end := steps[len(code)]
+	if !end.ok || end.pieces < 2 {
+		return candidate{}, false
+	}
+
+	runes := make([]rune, 0, end.pieces)
+	for i := len(code); i > 0; i = steps[i].from {
+		runes = append(runes, steps[i].r)
+	}
+	slices.Reverse(runes)
+
+	return candidate{text: string(runes), cost: end.pieces - 1, score: end.score}, true
+}
+
+// logFrequency is the log probability of r in the language of the
+// frequencies.
+func (c Converter) logFrequency(r rune) float64 {
+	f, ok := c.frequencies[r]
+	if !ok || f < minFrequency {
+		f = minFrequency
+	}
+
+	return math.Log(f / 100)
+}
+
+// editDistance is the Levenshtein distance between two codes.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func longestCode(morseToRune map[string]rune) int {
+	longest := 0
+	for code := range morseToRune {
+		longest = max(longest, len(code))
+	}
+
+	return longest
+}
+
+func mergeFrequencies(tables ...Frequencies) Frequencies {
+	ret := Frequencies{}
+	for _, f := range tables {
+		maps.Copy(ret, f)
+	}
+
+	return ret
+}
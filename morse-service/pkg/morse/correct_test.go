@@ -0,0 +1,114 @@
+package morse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrection(t *testing.T) {
+	tests := []struct {
+		name        string
+		alphabet    string
+		maxDistance int
+		resegment   bool
+		input       string
+		want        string
+		corrections []Correction
+	}{
+		{
+			name:        "known codes",
+			alphabet:    "latin",
+			maxDistance: 1,
+			resegment:   true,
+			input:       ".... . .-.. .-.. ---",
+			want:        "HELLO",
+		},
+		{
+			name:        "extra element",
+			alphabet:    "latin",
+			maxDistance: 1,
+			input:       ".-..-.-",
+			want:        `"`,
+			corrections: []Correction{{Position: Position{0, 1, 1}, Code: ".-..-.-", Text: `"`}},
+		},
+		{
+			name:      "lost separators",
+			alphabet:  "latin",
+			resegment: true,
+			input:     "...---...   -...---",
+			want:      "SOS BO",
+			corrections: []Correction{
+				{Position: Position{0, 1, 1}, Code: "...---...", Text: "SOS"},
+				{Position: Position{12, 1, 13}, Code: "-...---", Text: "BO"},
+			},
+		},
+		{
+			name:        "frequent letters win",
+			alphabet:    "russian",
+			resegment:   true,
+			input:       "...---...",
+			want:        "СОС",
+			corrections: []Correction{{Position: Position{0, 1, 1}, Code: "...---...", Text: "СОС"}},
+		},
+		{
+			name:        "edit before split",
+			alphabet:    "russian",
+			maxDistance: 1,
+			resegment:   true,
+			input:       "-------",
+			want:        "ОШ",
+			corrections: []Correction{{Position: Position{0, 1, 1}, Code: "-------", Text: "ОШ"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewConverterFor(tt.alphabet, WithCorrection(tt.maxDistance, tt.resegment))
+			require.NoError(t, err)
+
+			text, report := c.ToTextStrict(tt.input)
+			require.True(t, report.Lossless(), report.Err())
+			assert.Equal(t, tt.want, text)
+			assert.Equal(t, tt.corrections, report.Corrections)
+			assert.Equal(t, len(tt.corrections), report.Corrected)
+		})
+	}
+}
+
+func TestCorrectionLimits(t *testing.T) {
+	c, err := NewConverterFor("latin", WithCorrection(1, false))
+	require.NoError(t, err)
+
+	text, report := c.ToTextStrict("-------- hello")
+	assert.Empty(t, text)
+	assert.Empty(t, report.Corrections)
+	require.Equal(t, 2, report.Total)
+	assert.Equal(t, "--------", report.Issues[0].Err.Text)
+	assert.Equal(t, "hello", report.Issues[1].Err.Text)
+}
+
+func TestCorrectionOff(t *testing.T) {
+	text, report := DefaultConverter.ToTextStrict("-------")
+	assert.Empty(t, text)
+	assert.Equal(t, 1, report.Total)
+	assert.Zero(t, report.Corrected)
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"...", "", 3},
+		{".-", ".-", 0},
+		{".-", "-.", 2},
+		{".-..", ".-.", 1},
+		{"---", "-.-", 1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, editDistance(tt.a, tt.b), "%q %q", tt.a, tt.b)
+		assert.Equal(t, tt.want, editDistance(tt.b, tt.a), "%q %q", tt.b, tt.a)
+	}
+}
@@ -0,0 +1,292 @@
+package morse
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// clusterRatio is the smallest ratio between neighbouring durations that
+// is taken as the border between two kinds of elements: dots and dashes
+// are 1:3 apart, character and word gaps 3:7.
+const clusterRatio = 1.6
+
+// adaptRate is the weight of every new mark in the running dot length.
+const adaptRate = 0.2
+
+// keyer classifies key-down and key-up periods by a dot length that follows
+// the sender's speed. Word gaps are measured in dots, so they follow it too.
+type keyer struct {
+	unit     time.Duration
+	wordGaps float64
+}
+
+func newKeyer(elements []Element) keyer {
+	var marks, gaps []time.Duration
+	for _, e := range elements {
+		if e.On {
+			marks = append(marks, e.Dur)
+		} else {
+			gaps = append(gaps, e.Dur)
+		}
+	}
+
+	k := keyer{unit: estimateUnit(marks, gaps), wordGaps: 5}
+	if k.unit == 0 {
+		return k
+	}
+
+	var long []time.Duration
+	for _, g := range gaps {
+		if g >= 2*k.unit {
+			long = append(long, g)
+		}
+	}
+
+	if lo, hi, ok := split(long); ok {
+		k.wordGaps = math.Sqrt(float64(lo)*float64(hi)) / float64(k.unit)
+	} else if len(long) != 0 && long[len(long)/2] >= 5*k.unit {
+		// Farnsworth spacing can stretch every gap past 5 units; with a
+		// single kind of long gaps they are character gaps.
+		k.wordGaps = math.Inf(1)
+	}
+
+	return k
+}
+
+// estimateUnit guesses the dot length. Dots and dashes are told apart by
+// the largest jump between mark lengths; if all marks look alike, the
+// shortest gap, which separates elements within a character, decides.
+func estimateUnit(marks, gaps []time.Duration) time.Duration {
+	if len(marks) == 0 {
+		return 0
+	}
+
+	if dots, _, ok := split(marks); ok {
+		return dots
+	}
+
+	slices.Sort(marks)
+	mark := marks[len(marks)/2]
+
+	if len(gaps) != 0 {
+		if shortest := slices.Min(gaps); float64(mark)/float64(shortest) >= clusterRatio {
+			return mark / 3
+		}
+	}
+
+	return mark
+}
+
+// split sorts durations and finds the biggest relative jump between
+// neighbours. It returns the medians of both sides if the jump is large
+// enough to separate two kinds of elements.
+func split(durations []time.Duration) (time.Duration, time.Duration, bool) {
+	if len(durations) < 2 {
+		return 0, 0, false
+	}
+
+	slices.Sort(durations)
+
+	at, best := 0, 0.0
+	for i := 1; i < len(durations); i++ {
+		if ratio := float64(durations[i]) / float64(max(durations[i-1], 1)); ratio > best {
+			at, best = i, ratio
+		}
+	}
+	if best < clusterRatio {
+		return 0, 0, false
+	}
+
+	lo, hi := durations[:at], durations[at:]
+	return lo[len(lo)/2], hi[len(hi)/2], true
+}
+
+// mark returns '.' or '-' for a key-down period and adapts the dot length.
+func (k *keyer) mark(d time.Duration) byte {
+	symbol, unit := byte('.'), d
+	if d >= 2*k.unit {
+		symbol, unit = '-', d/3
+	}
+
+	k.unit += time.Duration(adaptRate * float64(unit-k.unit))
+
+	return symbol
+}
+
+type gapKind int
+
+const (
+	elementGap gapKind = iota
+	charGap
+	wordGap
+)
+
+func (k *keyer) gap(d time.Duration) gapKind {
+	switch {
+	case d < 2*k.unit:
+		return elementGap
+	case float64(d) < k.wordGaps*float64(k.unit):
+		return charGap
+	default:
+		return wordGap
+	}
+}
+
+// wpm converts the dot length into words per minute.
+func (k *keyer) wpm() float64 {
+	if k.unit <= 0 {
+		return 0
+	}
+
+	return float64(time.Minute) / parisUnits / float64(k.unit)
+}
+
+// FromElements turns key-down and key-up periods back into Morse code
+// written with c's separators, ready for ToText. The speed is estimated
+// from the elements and tracked as it drifts; the final estimate is
+// returned in words per minute.
+func (c Converter) FromElements(elements []Element) (string, float64) {
+	k := newKeyer(elements)
+
+	var out, code []byte
+	flush := func() {
+		out = c.notation.appendCode(out, string(code))
+		code = code[:0]
+	}
+
+	for _, e := range elements {
+		if e.On {
+			code = append(code, k.mark(e.Dur))
+			continue
+		}
+
+		if len(out) == 0 && len(code) == 0 {
+			continue
+		}
+
+		switch k.gap(e.Dur) {
+		case charGap:
+			flush()
+			out = append(out, c.charSeparator...)
+		case wordGap:
+			flush()
+			out = append(out, c.wordSeparator...)
+		}
+	}
+	flush()
+
+	return string(out), k.wpm()
+}
+
+// keyerWindow is how many recent periods a Keyer estimates the timing
+// from.
+const keyerWindow = 64
+
+// Keyer decodes a key worked live. Periods are passed in as they end and
+// text comes out as soon as the gap after a character or word is long
+// enough to tell. Until the sender has keyed both dots and dashes, marks
+// are told apart by the initial speed.
+type Keyer struct {
+	k      keyer
+	d      *Decoder
+	recent []Element
+	code   []byte
+	word   bool
+}
+
+// NewKeyer returns a keyer that starts out expecting wpm words per
+// minute.
+func (c Converter) NewKeyer(wpm float64) (*Keyer, error) {
+	t, err := NewTiming(wpm, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keyer{k: keyer{unit: t.Unit(), wordGaps: 5}, d: c.NewDecoder(nil)}, nil
+}
+
+// Key takes a key-down or key-up period that has ended and returns the
+// text it completed.
+func (k *Keyer) Key(e Element) string {
+	if len(k.recent) == keyerWindow {
+		k.recent = append(k.recent[:0], k.recent[1:]...)
+	}
+	k.recent = append(k.recent, e)
+	k.adapt()
+
+	if e.On {
+		k.code = append(k.code, k.k.mark(e.Dur))
+		return ""
+	}
+
+	return k.Idle(e.Dur)
+}
+
+// Idle returns the text completed by the key being up for d so far. The
+// same gap may be passed again as it grows, and once more to Key when it
+// ends; nothing is decoded twice.
+func (k *Keyer) Idle(d time.Duration) string {
+	kind := k.k.gap(d)
+
+	if kind != elementGap && len(k.code) != 0 {
+		k.d.decodeToken(k.code)
+		k.code = k.code[:0]
+		k.word = true
+	}
+	if kind == wordGap && k.word {
+		k.d.out = append(k.d.out, ' ')
+		k.word = false
+	}
+
+	out := string(k.d.out)
+	k.d.out = k.d.out[:0]
+
+	return out
+}
+
+// Wait returns how long a gap must last for Idle to decode more, or 0 if
+// nothing is pending.
+func (k *Keyer) Wait() time.Duration {
+	switch {
+	case len(k.code) != 0:
+		return 2 * k.k.unit
+	case k.word:
+		return time.Duration(k.k.wordGaps * float64(k.k.unit))
+	default:
+		return 0
+	}
+}
+
+// Pending returns the dots and dashes of the character being keyed.
+func (k *Keyer) Pending() string {
+	return string(k.code)
+}
+
+// WPM returns the current speed estimate.
+func (k *Keyer) WPM() float64 {
+	return k.k.wpm()
+}
+
+// adapt takes the timing from the recent periods once they hold both dots
+// and dashes; before that the marks alone adapt the dot length.
+func (k *Keyer) adapt() {
+	var marks []time.Duration
+	for _, e := range k.recent {
+		if e.On {
+			marks = append(marks, e.Dur)
+		}
+	}
+
+	if _, _, ok := split(marks); !ok {
+		return
+	}
+
+	// Long gaps all of one kind are taken for Farnsworth spaced characters
+	// in a recording; live they are as likely the first word gaps.
+	wordGaps := k.k.wordGaps
+	k.k = newKeyer(k.recent)
+	if math.IsInf(k.k.wordGaps, 1) {
+		k.k.wordGaps = wordGaps
+	}
+}
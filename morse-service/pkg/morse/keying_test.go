@@ -0,0 +1,107 @@
+package morse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromElementsSpeedDrift(t *testing.T) {
+	var elements []Element
+	for _, wpm := range []float64{14, 17, 20, 24} {
+		tm, err := NewTiming(wpm, 0)
+		require.NoError(t, err)
+
+		if len(elements) != 0 {
+			elements = append(elements, Element{On: false, Dur: 7 * tm.Unit()})
+		}
+		elements = append(elements, DefaultConverter.Elements(ToMorse("ПАРИС"), tm)...)
+	}
+
+	code, wpm := DefaultConverter.FromElements(elements)
+	assert.Equal(t, "ПАРИС ПАРИС ПАРИС ПАРИС", ToText(code))
+	assert.InDelta(t, 24, wpm, 2)
+}
+
+func TestFromElementsEmpty(t *testing.T) {
+	code, wpm := DefaultConverter.FromElements(nil)
+	assert.Empty(t, code)
+	assert.Zero(t, wpm)
+}
+
+// keyLive feeds the elements to a keyer the way a live key would: the
+// gap before every mark is first seen growing, then ends.
+func keyLive(k *Keyer, elements []Element) string {
+	var out strings.Builder
+	for _, e := range elements {
+		if !e.On {
+			for d := time.Duration(0); d < e.Dur; d += 10 * time.Millisecond {
+				out.WriteString(k.Idle(d))
+			}
+		}
+		out.WriteString(k.Key(e))
+	}
+
+	return out.String()
+}
+
+func TestKeyer(t *testing.T) {
+	tests := []struct {
+		name     string
+		sent     float64
+		expected float64
+	}{
+		{"expected speed", 20, 20},
+		{"faster", 28, 20},
+		{"slower", 12, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm, err := NewTiming(tt.sent, 0)
+			require.NoError(t, err)
+
+			k, err := DefaultConverter.NewKeyer(tt.expected)
+			require.NoError(t, err)
+
+			elements := DefaultConverter.Elements(ToMorse("ПАРИС ТЕСТ ПАРИС"), tm)
+			out := keyLive(k, elements)
+			assert.True(t, strings.HasPrefix(out, "ПАРИС ТЕСТ ПАРИ"), out)
+
+			// The key stays up after the last character.
+			out += k.Idle(tm.Unit() * 3)
+			out += k.Idle(tm.Unit() * 8)
+
+			assert.Equal(t, "ПАРИС ТЕСТ ПАРИС ", out)
+			assert.InDelta(t, tt.sent, k.WPM(), tt.sent/10)
+			assert.Zero(t, k.Wait())
+		})
+	}
+}
+
+func TestKeyerPending(t *testing.T) {
+	k, err := DefaultConverter.NewKeyer(20)
+	require.NoError(t, err)
+	unit := 60 * time.Millisecond
+
+	assert.Empty(t, k.Key(Element{On: true, Dur: unit}))
+	assert.Empty(t, k.Key(Element{On: false, Dur: unit}))
+	assert.Empty(t, k.Key(Element{On: true, Dur: 3 * unit}))
+	assert.Equal(t, ".-", k.Pending())
+	assert.Equal(t, 2*unit, k.Wait())
+
+	assert.Empty(t, k.Idle(unit))
+	assert.Equal(t, "А", k.Idle(3*unit))
+	assert.Empty(t, k.Pending())
+	assert.Equal(t, 5*unit, k.Wait())
+	assert.Equal(t, " ", k.Key(Element{On: false, Dur: 7 * unit}))
+	assert.Empty(t, k.Idle(20*unit))
+}
+
+func TestKeyerInvalidSpeed(t *testing.T) {
+	_, err := DefaultConverter.NewKeyer(0)
+	assert.ErrorIs(t, err, ErrInvalidTiming)
+}
@@ -1,8 +1,10 @@
 package morse
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"maps"
+	"slices"
 	"unicode"
 )
 
@@ -128,7 +130,7 @@ var DefaultMorse = EncodingMap{
 	'"':  IvertedComma,
 }
 
-var reverseDefaultMorse = reverseEncodingMap(DefaultMorse)
+var ErrNilEncodingMap = errors.New("using a nil EncodingMap")
 
 type ErrNoEncoding struct{ Text string }
 
@@ -136,54 +138,62 @@ func (e ErrNoEncoding) Error() string {
 	return fmt.Sprintf("No encoding for: %q", e.Text)
 }
 
+// ErrAmbiguousCode is returned by NewConverter when several runes share a
+// code and WithDecoding does not say which one to decode it to, or when
+// several prosigns share a code.
+type ErrAmbiguousCode struct {
+	Code     string
+	Runes    []rune
+	Prosigns []string
+}
+
+func (e ErrAmbiguousCode) Error() string {
+	text := string(e.Runes)
+	for _, p := range e.Prosigns {
+		text += string(prosignOpen) + p + string(prosignClose)
+	}
+
+	return fmt.Sprintf("Ambiguous code %q for: %q", e.Code, text)
+}
+
 func RuneToMorse(r rune) string {
 	r = unicode.ToUpper(r)
 	return DefaultMorse[r]
 }
 
 func MorseToRune(morse string) rune {
-	return reverseDefaultMorse[morse]
+	return DefaultConverter.alphabets[0].morseToRune[morse]
 }
 
-func reverseEncodingMap(encoding EncodingMap) map[string]rune {
-	ret := make(map[string]rune, len(encoding))
-
+func reverseEncodingMap(encoding EncodingMap, preferred map[string]rune) (map[string]rune, error) {
+	runes := make(map[string][]rune, len(encoding))
 	for k, v := range encoding {
-		ret[v] = k
+		runes[v] = append(runes[v], k)
 	}
 
-	return ret
-}
+	ret := make(map[string]rune, len(runes))
 
-func (c Converter) ToText(morse string) string {
-	out := make([]rune, 0, int(float64(len(morse))/averageSize))
-
-	words := strings.Split(morse, c.charSeparator+Space+c.charSeparator)
-	for _, word := range words {
-		chars := strings.Split(word, c.charSeparator)
-
-		for _, ch := range chars {
-			text, ok := c.morseToRune[ch]
-			if !ok {
-				hand := []rune(c.Handling(ErrNoEncoding{string(ch)}))
-				out = append(out, hand...)
-
-				if len(hand) != 0 {
-					out = append(out, []rune(c.charSeparator)...)
-				}
-				continue
-			}
-			out = append(out, text)
+	for _, code := range slices.Sorted(maps.Keys(runes)) {
+		candidates := runes[code]
+		if len(candidates) == 1 {
+			ret[code] = candidates[0]
+			continue
 		}
 
-		out = append(out, ' ')
+		r, ok := preferred[code]
+		if !ok || !slices.Contains(candidates, r) {
+			slices.Sort(candidates)
+			return nil, ErrAmbiguousCode{Code: code, Runes: candidates}
+		}
+		ret[code] = r
 	}
 
-	if !c.trailingSeparator && len(out) >= len(c.charSeparator) {
-		out = out[:len(out)-len(c.charSeparator)]
-	}
+	return ret, nil
+}
 
-	return string(out)
+func (c Converter) ToText(morse string) string {
+	text, _ := c.ToTextStrict(morse)
+	return text
 }
 
 type ConverterOption func(Converter) Converter
@@ -192,11 +202,21 @@ type ErrorHandler func(error) string
 
 type Converter struct {
 	runeToMorse       map[rune]string
-	morseToRune       map[string]rune
+	preferred         map[string]rune
+	baseShift         string
+	shiftTo           []Shift
+	alphabets         []alphabet
+	shifts            map[string]int
+	prosigns          map[string]string
+	prosignNames      map[string]string
+	maxProsign        int
 	charSeparator     string
 	wordSeparator     string
 	convertToUpper    bool
 	trailingSeparator bool
+	notation          Notation
+	frequencies       Frequencies
+	correction        correction
 
 	Handling ErrorHandler
 }
@@ -205,16 +225,13 @@ func IgnoreHandler(error) string {
 	return ""
 }
 
-func NewConverter(convertingMap EncodingMap, options ...ConverterOption) Converter {
+func NewConverter(convertingMap EncodingMap, options ...ConverterOption) (Converter, error) {
 	if convertingMap == nil {
-		panic("Using a nil EncodingMap")
+		return Converter{}, ErrNilEncodingMap
 	}
 
-	morseToRune := reverseEncodingMap(convertingMap)
-
 	c := Converter{
 		runeToMorse:       convertingMap,
-		morseToRune:       morseToRune,
 		charSeparator:     " ",
 		wordSeparator:     "",
 		convertToUpper:    false,
@@ -227,6 +244,27 @@ func NewConverter(convertingMap EncodingMap, options ...ConverterOption) Convert
 		c = opt(c)
 	}
 
+	if err := c.build(); err != nil {
+		return Converter{}, err
+	}
+
+	return c, nil
+}
+
+// build checks the options of c and derives the lookup tables from them.
+func (c *Converter) build() error {
+	if c.runeToMorse == nil {
+		return ErrNilEncodingMap
+	}
+
+	if err := c.buildAlphabets(); err != nil {
+		return err
+	}
+
+	if err := c.buildProsigns(); err != nil {
+		return err
+	}
+
 	if c.wordSeparator == "" {
 		sp, ok := c.runeToMorse[' ']
 		if !ok {
@@ -236,41 +274,29 @@ func NewConverter(convertingMap EncodingMap, options ...ConverterOption) Convert
 		c.wordSeparator = c.charSeparator + sp + c.charSeparator
 	}
 
-	return c
+	return nil
 }
 
-func (c Converter) ToMorse(text string) string {
-	out := make([]rune, 0, int(float64(len(text))*averageSize))
-
-	for _, ch := range text {
-		if c.convertToUpper {
-			ch = unicode.ToUpper(ch)
-		}
-
-		if _, ok := c.runeToMorse[ch]; !ok {
-			hand := []rune(c.Handling(ErrNoEncoding{string(ch)}))
-			out = append(out, hand...)
-
-			if len(hand) != 0 {
-				out = append(out, []rune(c.charSeparator)...)
-			}
-			continue
-		}
-
-		out = append(out, []rune(c.runeToMorse[ch])...)
-		out = append(out, []rune(c.charSeparator)...)
+func MustNewConverter(convertingMap EncodingMap, options ...ConverterOption) Converter {
+	c, err := NewConverter(convertingMap, options...)
+	if err != nil {
+		panic(err)
 	}
 
-	if !c.trailingSeparator && len(out) >= len(c.charSeparator) {
-		out = out[:len(out)-len(c.charSeparator)]
-	}
+	return c
+}
 
-	return string(out)
+func (c Converter) ToMorse(text string) string {
+	morse, _ := c.ToMorseStrict(text)
+	return morse
 }
 
-var DefaultConverter = NewConverter(
+var DefaultConverter = MustNewConverter(
 	DefaultMorse,
 
+	WithDecoding(ЪЬ, 'Ь'),
+	WithFrequencies(RussianFrequencies),
+	WithProsigns(Prosigns),
 	WithCharSeparator(" "),
 	WithWordSeparator("   "),
 	WithLowercaseHandling(true),
@@ -320,3 +346,15 @@ func WithWordSeparator(wordSeparator string) ConverterOption {
 		return c
 	}
 }
+
+// WithDecoding picks the rune a code shared by several runes decodes to.
+func WithDecoding(code string, r rune) ConverterOption {
+	return func(c Converter) Converter {
+		c.preferred = maps.Clone(c.preferred)
+		if c.preferred == nil {
+			c.preferred = make(map[string]rune)
+		}
+		c.preferred[code] = r
+		return c
+	}
+}
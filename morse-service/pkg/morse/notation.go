@@ -0,0 +1,208 @@
+package morse
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Notation is a way of writing dots and dashes. Converters work with '.'
+// and '-' internally; the notation renders codes on output and reads its
+// spellings back on input.
+type Notation struct {
+	name     string
+	dot      string
+	lastDot  string
+	dash     string
+	join     string
+	charSep  string
+	space    string
+	elements map[string]byte
+}
+
+var (
+	// NotationStandard is the zero Notation: '.' and '-'.
+	NotationStandard = Notation{name: "standard"}
+
+	// NotationUnicode writes middle dots and minus signs, and reads the
+	// bullets and dashes people paste as well.
+	NotationUnicode = Notation{
+		name: "unicode",
+		dot:  "·",
+		dash: "−",
+		elements: map[string]byte{
+			".": '.', "·": '.', "•": '.', "∙": '.',
+			"-": '-', "−": '-', "–": '-', "—": '-',
+		},
+	}
+
+	NotationUnderscore = Notation{
+		name:     "underscore",
+		dot:      ".",
+		dash:     "_",
+		elements: map[string]byte{".": '.', "_": '-', "-": '-'},
+	}
+
+	// NotationDitDah spells codes out the way they sound, e.g. "di-dah"
+	// for A; a dot at the end of a character is "dit".
+	NotationDitDah = Notation{
+		name:     "dit-dah",
+		dot:      "di",
+		lastDot:  "dit",
+		dash:     "dah",
+		join:     "-",
+		elements: map[string]byte{"di": '.', "dit": '.', "da": '-', "dah": '-'},
+	}
+
+	// NotationBinary writes the keying as on/off bits in dot units: "1"
+	// and "111" for the elements, "0", "000" and "0000000" for the gaps.
+	// It replaces the converter separators.
+	NotationBinary = Notation{
+		name:     "binary",
+		dot:      "1",
+		dash:     "111",
+		join:     "0",
+		charSep:  "000",
+		space:    "0",
+		elements: map[string]byte{"1": '.', "111": '-'},
+	}
+)
+
+var notations = []Notation{NotationStandard, NotationUnicode, NotationUnderscore, NotationDitDah, NotationBinary}
+
+// Notations returns the known notations, NotationStandard first.
+func Notations() []Notation {
+	return append([]Notation(nil), notations...)
+}
+
+// LookupNotation returns the notation with the given name, ignoring case.
+func LookupNotation(name string) (Notation, bool) {
+	for _, n := range notations {
+		if strings.EqualFold(n.Name(), name) {
+			return n, true
+		}
+	}
+
+	return Notation{}, false
+}
+
+func (n Notation) Name() string {
+	if n.name == "" {
+		return NotationStandard.name
+	}
+
+	return n.name
+}
+
+// Parse reads a single character written in n back as dots and dashes. It
+// reports whether the token consists of n's elements only; if not, the
+// token is returned as is.
+func (n Notation) Parse(token string) (string, bool) {
+	if n.elements == nil {
+		return token, isCode(token)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(token))
+
+	if n.join == "" {
+		for _, r := range token {
+			e, ok := n.elements[string(r)]
+			if !ok {
+				return token, false
+			}
+			sb.WriteByte(e)
+		}
+
+		return sb.String(), sb.Len() != 0
+	}
+
+	for part := range strings.SplitSeq(token, n.join) {
+		e, ok := n.elements[strings.ToLower(part)]
+		if !ok {
+			return token, false
+		}
+		sb.WriteByte(e)
+	}
+
+	return sb.String(), true
+}
+
+// appendCode renders code in n. Runes other than '.' and '-', as returned
+// by an ErrorHandler, are kept.
+func (n Notation) appendCode(out []byte, code string) []byte {
+	if n.elements == nil {
+		return append(out, code...)
+	}
+
+	last := len(code) - 1
+	for i, r := range code {
+		if i != 0 {
+			out = append(out, n.join...)
+		}
+
+		switch {
+		case r == '.' && i == last && n.lastDot != "":
+			out = append(out, n.lastDot...)
+		case r == '.':
+			out = append(out, n.dot...)
+		case r == '-':
+			out = append(out, n.dash...)
+		default:
+			out = utf8.AppendRune(out, r)
+		}
+	}
+
+	return out
+}
+
+// wordSpace is what stands between two character separators to separate
+// words.
+func (n Notation) wordSpace() string {
+	if n.space != "" {
+		return n.space
+	}
+
+	return Space
+}
+
+func isCode(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '.' && s[i] != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithNotation sets how codes are written and read. Notations with fixed
+// separators, like NotationBinary, replace the current separators.
+func WithNotation(n Notation) ConverterOption {
+	return func(c Converter) Converter {
+		c.notation = n
+		if n.charSep != "" {
+			c.charSeparator = n.charSep
+			c.wordSeparator = n.charSep + n.space + n.charSep
+		}
+		return c
+	}
+}
+
+// WithNotation returns a copy of c that reads and writes n, checked like a
+// new converter.
+func (c Converter) WithNotation(n Notation) (Converter, error) {
+	c = WithNotation(n)(c)
+	if err := c.build(); err != nil {
+		return Converter{}, err
+	}
+
+	return c, nil
+}
+
+func (c Converter) Notation() Notation {
+	return c.notation
+}
@@ -0,0 +1,133 @@
+package morse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func notationConverter(t *testing.T, n Notation) Converter {
+	c, err := NewConverter(DefaultMorse, WithDecoding(ЪЬ, 'Ь'), WithProsigns(Prosigns), WithNotation(n))
+	require.NoError(t, err)
+	return c
+}
+
+func TestNotationRoundTrip(t *testing.T) {
+	tests := []struct {
+		notation Notation
+		encoded  string
+		words    string
+	}{
+		{NotationStandard, "... --- ... ...-.-", "... --- ...   -"},
+		{NotationUnicode, "··· −−− ··· ···−·−", "··· −−− ···   −"},
+		{NotationUnderscore, "... ___ ... ..._._", "... ___ ...   _"},
+		{NotationDitDah, "di-di-dit dah-dah-dah di-di-dit di-di-di-dah-di-dah", "di-di-dit dah-dah-dah di-di-dit   dah"},
+		{NotationBinary, "101010001110111011100010101000101010111010111", "1010100011101110111000101010000000111"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.notation.Name(), func(t *testing.T) {
+			c := notationConverter(t, tt.notation)
+
+			morse, report := c.ToMorseStrict("СОС<SK>")
+			require.True(t, report.Lossless(), report.Err())
+			assert.Equal(t, tt.encoded, morse)
+
+			text, report := c.ToTextStrict(morse)
+			require.True(t, report.Lossless(), report.Err())
+			assert.Equal(t, "СОС<SK>", text)
+
+			text, report = c.ToTextStrict(tt.words)
+			require.True(t, report.Lossless(), report.Err())
+			assert.Equal(t, "СОС Т", text)
+		})
+	}
+}
+
+func TestNotationInput(t *testing.T) {
+	tests := []struct {
+		notation Notation
+		input    string
+		want     string
+	}{
+		{NotationUnicode, "•−− ∙ – —", "ВЕТТ"},
+		{NotationUnicode, ".-- .", "ВЕ"},
+		{NotationUnderscore, ".-- ._", "ВА"},
+		{NotationDitDah, "Di-Dah DAH-dit da", "АНТ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.notation.Name()+" "+tt.input, func(t *testing.T) {
+			text, report := notationConverter(t, tt.notation).ToTextStrict(tt.input)
+			require.True(t, report.Lossless(), report.Err())
+			assert.Equal(t, tt.want, text)
+		})
+	}
+}
+
+func TestNotationReportsOriginalToken(t *testing.T) {
+	_, report := notationConverter(t, NotationDitDah).ToTextStrict("di-dah bee dah")
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, "bee", report.Issues[0].Err.Text)
+	assert.Equal(t, Position{Offset: 7, Line: 1, Column: 8}, report.Issues[0].Position)
+}
+
+func TestNotationSignal(t *testing.T) {
+	tm, err := NewTiming(20, 0)
+	require.NoError(t, err)
+
+	want := DefaultConverter.Elements("... --- ...   -", tm)
+	for _, n := range Notations() {
+		c := notationConverter(t, n)
+		code, _ := c.ToMorseStrict("СОС")
+
+		got := c.Elements(code+c.decodeWordSeparator()+c.ToMorse("Т"), tm)
+		assert.Equal(t, want, got, n.Name())
+
+		decoded, _ := c.FromElements(got)
+		assert.Equal(t, "СОС Т", c.ToText(decoded), n.Name())
+	}
+}
+
+func TestLookupNotation(t *testing.T) {
+	n, ok := LookupNotation("Dit-Dah")
+	require.True(t, ok)
+	assert.Equal(t, "dit-dah", n.Name())
+
+	_, ok = LookupNotation("semaphore")
+	assert.False(t, ok)
+
+	assert.Equal(t, "standard", Notation{}.Name())
+	assert.Equal(t, "standard", DefaultConverter.Notation().Name())
+}
+
+func TestNotationParse(t *testing.T) {
+	for _, tt := range []struct {
+		notation Notation
+		token    string
+		want     string
+		ok       bool
+	}{
+		{NotationStandard, ".-", ".-", true},
+		{NotationStandard, "", "", false},
+		{NotationStandard, ".x", ".x", false},
+		{NotationUnicode, "·−", ".-", true},
+		{NotationDitDah, "di-dah", ".-", true},
+		{NotationDitDah, "di--dah", "di--dah", false},
+		{NotationBinary, "10111", ".-", true},
+		{NotationBinary, "1011", "1011", false},
+	} {
+		got, ok := tt.notation.Parse(tt.token)
+		assert.Equal(t, tt.want, got, tt.token)
+		assert.Equal(t, tt.ok, ok, tt.token)
+	}
+}
+
+func TestConverterWithNotation(t *testing.T) {
+	c, err := DefaultConverter.WithNotation(NotationDitDah)
+	require.NoError(t, err)
+	assert.Equal(t, "di-di-dit dah-dah-dah di-di-dit", c.ToMorse("СОС"))
+	assert.Equal(t, NotationStandard.Name(), DefaultConverter.Notation().Name())
+
+	_, err = Converter{}.WithNotation(NotationDitDah)
+	assert.ErrorIs(t, err, ErrNilEncodingMap)
+}
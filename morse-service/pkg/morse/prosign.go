@@ -0,0 +1,82 @@
+package morse
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Procedural signals are sent as one code without inter-character gaps.
+const (
+	ProsignAR  = Cross
+	ProsignAS  = ".-..."
+	ProsignBT  = DoubleHyphen
+	ProsignCT  = "-.-.-"
+	ProsignHH  = "........"
+	ProsignKN  = "-.--."
+	ProsignSK  = "...-.-"
+	ProsignSN  = "...-."
+	ProsignSOS = "...---..."
+)
+
+// Prosigns maps prosign names to their codes. In text a prosign is written
+// as its name in angle brackets, e.g. "<SK>".
+var Prosigns = map[string]string{
+	"AR":  ProsignAR,
+	"AS":  ProsignAS,
+	"BT":  ProsignBT,
+	"CT":  ProsignCT,
+	"HH":  ProsignHH,
+	"KN":  ProsignKN,
+	"SK":  ProsignSK,
+	"SN":  ProsignSN,
+	"SOS": ProsignSOS,
+}
+
+const (
+	prosignOpen  = '<'
+	prosignClose = '>'
+)
+
+// WithProsigns enables the "<NAME>" syntax for the given prosigns. When a
+// prosign shares its code with a character, decoding yields the character.
+func WithProsigns(prosigns map[string]string) ConverterOption {
+	return func(c Converter) Converter {
+		c.prosigns = prosigns
+		return c
+	}
+}
+
+func (c *Converter) buildProsigns() error {
+	c.prosignNames = nil
+	c.maxProsign = 0
+	if len(c.prosigns) == 0 {
+		return nil
+	}
+
+	c.prosignNames = make(map[string]string, len(c.prosigns))
+	for _, name := range slices.Sorted(maps.Keys(c.prosigns)) {
+		code := c.prosigns[name]
+		if prev, dup := c.prosignNames[code]; dup {
+			return ErrAmbiguousCode{Code: code, Prosigns: []string{prev, name}}
+		}
+		if _, ok := c.shifts[code]; ok {
+			return ErrInvalidShift{Code: code, Prosign: name}
+		}
+
+		c.prosignNames[code] = name
+		c.maxProsign = max(c.maxProsign, utf8.RuneCountInString(name))
+	}
+
+	return nil
+}
+
+func (c Converter) prosignCode(name string) (string, bool) {
+	if c.convertToUpper {
+		name = strings.ToUpper(name)
+	}
+
+	code, ok := c.prosigns[name]
+	return code, ok
+}
@@ -0,0 +1,54 @@
+package morse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProsigns(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		morse string
+		back  string
+	}{
+		{"single", "<SOS>", ProsignSOS, "<SOS>"},
+		{"lowercase", "конец <sk>", "-.- --- -. . -.-.   " + ProsignSK, "КОНЕЦ <SK>"},
+		{"shared with char", "<KN>", ProsignKN, "("},
+		{"unknown", "<XY>", "", ""},
+		{"unterminated", "<АР", ".- .-.", "АР"},
+		{"nested", "<<BT>", ProsignBT, "<BT>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			morse := ToMorse(tt.text)
+			assert.Equal(t, tt.morse, morse)
+			assert.Equal(t, tt.back, ToText(morse))
+		})
+	}
+}
+
+func TestProsignsDisabled(t *testing.T) {
+	c := MustNewConverter(LatinMorse, WithHandler(func(error) string { return "?" }))
+	assert.Equal(t, "? ... -.- ?", c.ToMorse("<SK>"))
+}
+
+func TestProsignsAmbiguous(t *testing.T) {
+	_, err := NewConverter(LatinMorse, WithProsigns(map[string]string{"AR": ProsignAR, "PLUS": ProsignAR}))
+
+	var amb ErrAmbiguousCode
+	require.ErrorAs(t, err, &amb)
+	assert.Equal(t, []string{"AR", "PLUS"}, amb.Prosigns)
+
+	_, err = NewConverter(DefaultMorse,
+		WithDecoding(ЪЬ, 'Ь'),
+		WithProsigns(map[string]string{"ЛАТ": ShiftLatin}),
+		WithShifts(ShiftRussian, Shift{Code: ShiftLatin, Encoding: LatinMorse}),
+	)
+
+	var shiftErr ErrInvalidShift
+	require.ErrorAs(t, err, &shiftErr)
+	assert.Equal(t, "ЛАТ", shiftErr.Prosign)
+}
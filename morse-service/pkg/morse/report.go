@@ -0,0 +1,152 @@
+package morse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxReportedIssues limits the issues kept in a ConversionReport; the
+// total count is still exact.
+const MaxReportedIssues = 1000
+
+// Position is a place in the converter input. Offset is in bytes, Line and
+// Column start at 1 and Column counts runes.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+func (p *Position) advance(r rune, size int) {
+	if p.Line == 0 {
+		p.Line, p.Column = 1, 1
+	}
+
+	p.Offset += size
+	if r == '\n' {
+		p.Line++
+		p.Column = 1
+		return
+	}
+	p.Column++
+}
+
+func (p *Position) advanceBytes(b []byte) {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		p.advance(r, size)
+		b = b[size:]
+	}
+}
+
+func (p Position) normalized() Position {
+	if p.Line == 0 {
+		p.Line, p.Column = 1, 1
+	}
+
+	return p
+}
+
+// Issue is an input sequence the converter had no encoding for.
+type Issue struct {
+	Position
+	Err ErrNoEncoding
+}
+
+func (i Issue) Error() string {
+	return fmt.Sprintf("%v: %v", i.Position, i.Err)
+}
+
+func (i Issue) Unwrap() error {
+	return i.Err
+}
+
+// Correction is an unknown code the decoder replaced with the text it most
+// likely stands for; see WithCorrection.
+type Correction struct {
+	Position
+	Code string
+	Text string
+}
+
+func (c Correction) String() string {
+	return fmt.Sprintf("%v: %q corrected to %q", c.Position, c.Code, c.Text)
+}
+
+// ConversionReport lists the input that was lost or replaced by the
+// ErrorHandler during a conversion, and the codes that were corrected.
+// Corrections are not issues: a corrected conversion is lossless, if not
+// necessarily right.
+type ConversionReport struct {
+	Issues      []Issue
+	Total       int
+	Corrections []Correction
+	Corrected   int
+}
+
+func (r *ConversionReport) add(pos Position, text string) {
+	r.Total++
+	if len(r.Issues) < MaxReportedIssues {
+		r.Issues = append(r.Issues, Issue{Position: pos.normalized(), Err: ErrNoEncoding{Text: text}})
+	}
+}
+
+func (r *ConversionReport) correct(pos Position, code, text string) {
+	r.Corrected++
+	if len(r.Corrections) < MaxReportedIssues {
+		r.Corrections = append(r.Corrections, Correction{Position: pos.normalized(), Code: code, Text: text})
+	}
+}
+
+func (r ConversionReport) Lossless() bool {
+	return r.Total == 0
+}
+
+// Err returns the reported issues as a single error, or nil.
+func (r ConversionReport) Err() error {
+	if r.Total == 0 {
+		return nil
+	}
+
+	errs := make([]error, 0, len(r.Issues)+1)
+	for _, i := range r.Issues {
+		errs = append(errs, i)
+	}
+	if r.Total > len(r.Issues) {
+		errs = append(errs, fmt.Errorf("and %d more", r.Total-len(r.Issues)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ToMorseStrict works like ToMorse and also reports every rune that had no
+// encoding.
+func (c Converter) ToMorseStrict(text string) (string, ConversionReport) {
+	var sb strings.Builder
+	sb.Grow(int(float64(len(text)) * averageSize))
+
+	enc := c.NewEncoder(&sb)
+	_, _ = io.WriteString(enc, text)
+	_ = enc.Close()
+
+	return sb.String(), enc.Report()
+}
+
+// ToTextStrict works like ToText and also reports every code that had no
+// decoding.
+func (c Converter) ToTextStrict(morse string) (string, ConversionReport) {
+	var sb strings.Builder
+	sb.Grow(int(float64(len(morse)) / averageSize))
+
+	dec := c.NewDecoder(strings.NewReader(morse))
+	_, _ = io.Copy(&sb, dec)
+
+	return sb.String(), dec.Report()
+}
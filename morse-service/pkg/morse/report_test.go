@@ -0,0 +1,52 @@
+package morse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToMorseStrict(t *testing.T) {
+	morse, report := DefaultConverter.ToMorseStrict("да\nqД<XY>")
+	assert.Equal(t, "-.. .-   -..", morse)
+
+	require.Equal(t, 5, report.Total)
+	assert.False(t, report.Lossless())
+	assert.Equal(t, []Issue{
+		{Position: Position{Offset: 5, Line: 2, Column: 1}, Err: ErrNoEncoding{"Q"}},
+		{Position: Position{Offset: 8, Line: 2, Column: 3}, Err: ErrNoEncoding{"<"}},
+		{Position: Position{Offset: 9, Line: 2, Column: 4}, Err: ErrNoEncoding{"X"}},
+		{Position: Position{Offset: 10, Line: 2, Column: 5}, Err: ErrNoEncoding{"Y"}},
+		{Position: Position{Offset: 11, Line: 2, Column: 6}, Err: ErrNoEncoding{">"}},
+	}, report.Issues)
+
+	var noEnc ErrNoEncoding
+	require.ErrorAs(t, report.Err(), &noEnc)
+}
+
+func TestToTextStrict(t *testing.T) {
+	text, report := DefaultConverter.ToTextStrict("-.. ........-\n.-   ..-- ------")
+	assert.Equal(t, "Д А Ю", text)
+
+	require.Equal(t, 2, report.Total)
+	assert.Equal(t, Issue{Position: Position{Offset: 4, Line: 1, Column: 5}, Err: ErrNoEncoding{"........-"}}, report.Issues[0])
+	assert.Equal(t, Issue{Position: Position{Offset: 24, Line: 2, Column: 11}, Err: ErrNoEncoding{"------"}}, report.Issues[1])
+	assert.Equal(t, "2:11: No encoding for: \"------\"", report.Issues[1].Error())
+}
+
+func TestReportLimit(t *testing.T) {
+	in := make([]byte, MaxReportedIssues+10)
+	for i := range in {
+		in[i] = 'z'
+	}
+
+	_, report := DefaultConverter.ToMorseStrict(string(in))
+	assert.Equal(t, MaxReportedIssues+10, report.Total)
+	assert.Len(t, report.Issues, MaxReportedIssues)
+	assert.ErrorContains(t, report.Err(), "and 10 more")
+
+	_, report = DefaultConverter.ToMorseStrict("привет")
+	assert.True(t, report.Lossless())
+	assert.NoError(t, report.Err())
+}
@@ -0,0 +1,155 @@
+package morse
+
+import (
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTripOptions are the option combinations a round trip must survive.
+func roundTripOptions() [][]ConverterOption {
+	keep := func(c Converter) Converter { return c }
+
+	dimensions := [][]ConverterOption{
+		{WithNotation(NotationStandard), WithNotation(NotationUnicode), WithNotation(NotationUnderscore),
+			WithNotation(NotationDitDah), WithNotation(NotationBinary)},
+		{keep, WithCharSeparator("|"), WithCharSeparator("  ")},
+		{keep, WithWordSeparator(" / "), WithWordSeparator(" | "), WithWordSeparator("\n"), WithWordSeparator("#")},
+		{WithLowercaseHandling(false), WithLowercaseHandling(true)},
+		{WithTrailingSeparator(false), WithTrailingSeparator(true)},
+		{keep, WithProsigns(Prosigns)},
+	}
+
+	combinations := [][]ConverterOption{nil}
+	for _, dim := range dimensions {
+		var next [][]ConverterOption
+		for _, prefix := range combinations {
+			for _, opt := range dim {
+				next = append(next, append(slices.Clip(prefix), opt))
+			}
+		}
+		combinations = next
+	}
+
+	return combinations
+}
+
+// roundTripText returns random text made of c's runes, their lowercase
+// forms, whitespace and runes c cannot encode.
+func roundTripText(r *rand.Rand, c Converter) string {
+	var pool []rune
+	for _, a := range c.alphabets {
+		for _, ch := range a.morseToRune {
+			pool = append(pool, ch, unicode.ToLower(ch))
+		}
+	}
+	slices.Sort(pool)
+	pool = append(pool, ' ', ' ', ' ', '\t', '\n', '€', '~')
+
+	runes := make([]rune, r.Intn(40))
+	for i := range runes {
+		runes[i] = pool[r.Intn(len(pool))]
+	}
+
+	return string(runes)
+}
+
+// normalize is what a round trip is expected to make of text: the runes c
+// cannot encode are dropped and whitespace is collapsed into single spaces
+// between words.
+func normalize(text string, c Converter) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if c.convertToUpper {
+			r = unicode.ToUpper(r)
+		}
+		if unicode.IsSpace(r) {
+			sb.WriteRune(' ')
+			continue
+		}
+		if c.alphabetOf(r) >= 0 {
+			sb.WriteRune(r)
+		}
+	}
+
+	out := strings.Join(strings.Fields(sb.String()), " ")
+	if c.trailingSeparator && out != "" {
+		out += " "
+	}
+
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, name := range Alphabets() {
+		for i, options := range roundTripOptions() {
+			c, err := NewConverterFor(name, options...)
+			require.NoError(t, err)
+
+			cfg := &quick.Config{
+				MaxCount: 10,
+				Rand:     rand.New(rand.NewSource(int64(i))),
+				Values: func(args []reflect.Value, r *rand.Rand) {
+					args[0] = reflect.ValueOf(roundTripText(r, c))
+				},
+			}
+			roundTrip := func(text string) bool {
+				return c.ToText(c.ToMorse(text)) == normalize(text, c)
+			}
+
+			if err := quick.Check(roundTrip, cfg); err != nil {
+				var in string
+				if ce, ok := err.(*quick.CheckError); ok {
+					in = ce.In[0].(string)
+				}
+				t.Fatalf("%s, notation %s, separators %q %q: %v\nmorse: %q\ntext:  %q\nwant:  %q",
+					name, c.notation.Name(), c.charSeparator, c.wordSeparator, err,
+					c.ToMorse(in), c.ToText(c.ToMorse(in)), normalize(in, c))
+			}
+		}
+	}
+}
+
+func TestWordSeparators(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ConverterOption
+		morse   string
+		want    string
+	}{
+		{"default", nil, ".- -...   -.-.", "АБ Ц"},
+		{"slash", nil, ".- -... / -.-.", "АБ Ц"},
+		{"bar", nil, ".- -... | -.-.", "АБ Ц"},
+		{"line breaks", nil, ".- -...\r\n-.-.\n\n.-", "АБ Ц А"},
+		{"configured", []ConverterOption{WithWordSeparator(" // ")}, ".- -... // -.-.", "АБ Ц"},
+		{"configured with char separator", []ConverterOption{WithCharSeparator("|"), WithWordSeparator("||")}, ".-|-...||-.-.", "АБ Ц"},
+		{"bar as char separator", []ConverterOption{WithCharSeparator("|")}, ".-|-...|/|-.-.", "АБ Ц"},
+		{"collapsed", nil, "   / .- /   / -.-.  |  ", "А Ц"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewConverterFor(DefaultAlphabet, tt.options...)
+			require.NoError(t, err)
+
+			text, report := c.ToTextStrict(tt.morse)
+			require.True(t, report.Lossless(), report.Err())
+			assert.Equal(t, tt.want, text)
+		})
+	}
+}
+
+func TestEncoderWordSeparator(t *testing.T) {
+	c := MustNewConverter(DefaultMorse, WithDecoding(ЪЬ, 'Ь'), WithWordSeparator(" / "))
+
+	morse, report := c.ToMorseStrict("  АБ \t\nЦ  ")
+	require.True(t, report.Lossless(), report.Err())
+	assert.Equal(t, ".- -... / -.-.", morse)
+	assert.Equal(t, "АБ Ц", c.ToText(morse))
+}
@@ -0,0 +1,111 @@
+package morse
+
+import "fmt"
+
+// Alphabet switch signals after the Russian "РУС"/"ЛАТ" convention: the
+// letters of the word are sent as a single code without gaps.
+const (
+	ShiftRussian = Р + У + С
+	ShiftLatin   = Л + А + Т
+)
+
+// Shift is an additional alphabet selected by sending Code.
+type Shift struct {
+	Code     string
+	Encoding EncodingMap
+}
+
+// ErrInvalidShift is returned by NewConverter when a shift code is empty,
+// used twice or is also the code of a rune or a prosign.
+type ErrInvalidShift struct {
+	Code    string
+	Rune    rune
+	Prosign string
+}
+
+func (e ErrInvalidShift) Error() string {
+	if e.Rune != 0 {
+		return fmt.Sprintf("Shift code %q collides with: %q", e.Code, e.Rune)
+	}
+	if e.Prosign != "" {
+		return fmt.Sprintf("Shift code %q collides with prosign: %q", e.Code, e.Prosign)
+	}
+
+	return fmt.Sprintf("Invalid shift code: %q", e.Code)
+}
+
+type alphabet struct {
+	shift       string
+	runeToMorse EncodingMap
+	morseToRune map[string]rune
+	longest     int
+}
+
+// WithShifts lets a converter mix alphabets. The encoder emits a shift code
+// whenever a rune is missing from the current alphabet but present in
+// another one, and the decoder switches alphabets on shift codes. baseShift
+// selects the alphabet the converter was created with, which is also the
+// initial one.
+func WithShifts(baseShift string, shifts ...Shift) ConverterOption {
+	return func(c Converter) Converter {
+		c.baseShift = baseShift
+		c.shiftTo = shifts
+		return c
+	}
+}
+
+func (c *Converter) buildAlphabets() error {
+	tables := append([]Shift{{Code: c.baseShift, Encoding: c.runeToMorse}}, c.shiftTo...)
+
+	c.alphabets = make([]alphabet, 0, len(tables))
+	for _, t := range tables {
+		if t.Encoding == nil {
+			return ErrNilEncodingMap
+		}
+
+		morseToRune, err := reverseEncodingMap(t.Encoding, c.preferred)
+		if err != nil {
+			return err
+		}
+
+		c.alphabets = append(c.alphabets, alphabet{
+			shift:       t.Code,
+			runeToMorse: t.Encoding,
+			morseToRune: morseToRune,
+			longest:     longestCode(morseToRune),
+		})
+	}
+
+	c.shifts = nil
+	if len(c.alphabets) == 1 {
+		return nil
+	}
+
+	c.shifts = make(map[string]int, len(c.alphabets))
+	for i, a := range c.alphabets {
+		if _, dup := c.shifts[a.shift]; dup || a.shift == "" {
+			return ErrInvalidShift{Code: a.shift}
+		}
+
+		for _, other := range c.alphabets {
+			if r, ok := other.morseToRune[a.shift]; ok {
+				return ErrInvalidShift{Code: a.shift, Rune: r}
+			}
+		}
+
+		c.shifts[a.shift] = i
+	}
+
+	return nil
+}
+
+// alphabetOf returns the index of the first alphabet that can encode r.
+func (c Converter) alphabetOf(r rune) int {
+	for i, a := range c.alphabets {
+		if _, ok := a.runeToMorse[r]; ok {
+			return i
+		}
+	}
+
+	return -1
+}
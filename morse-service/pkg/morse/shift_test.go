@@ -0,0 +1,57 @@
+package morse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConverterAmbiguousCode(t *testing.T) {
+	_, err := NewConverter(DefaultMorse)
+
+	var amb ErrAmbiguousCode
+	require.ErrorAs(t, err, &amb)
+	assert.Equal(t, ЪЬ, amb.Code)
+	assert.Equal(t, []rune{'Ъ', 'Ь'}, amb.Runes)
+
+	c, err := NewConverter(DefaultMorse, WithDecoding(ЪЬ, 'Ъ'))
+	require.NoError(t, err)
+	assert.Equal(t, "ЪЪЪ", c.ToText("-..- -..- -..-"))
+
+	_, err = NewConverter(DefaultMorse, WithDecoding(ЪЬ, 'A'))
+	require.ErrorAs(t, err, &amb)
+
+	_, err = NewConverter(nil)
+	require.ErrorIs(t, err, ErrNilEncodingMap)
+}
+
+func TestShifts(t *testing.T) {
+	c, err := NewConverterFor("russian-latin", WithLowercaseHandling(true))
+	require.NoError(t, err)
+
+	morse := c.ToMorse("Да, yes 1")
+	assert.Equal(t, "-.. .- .-.-.-   "+ShiftLatin+" -.-- . ...   .----", morse)
+	assert.Equal(t, "ДА, YES 1", c.ToText(morse))
+	assert.Equal(t, "ДА", c.ToText(ShiftLatin+" "+ShiftRussian+" -.. .-"))
+}
+
+func TestInvalidShifts(t *testing.T) {
+	tests := []struct {
+		name      string
+		baseShift string
+		shift     Shift
+	}{
+		{"empty", "", Shift{Code: ShiftLatin, Encoding: LatinMorse}},
+		{"duplicate", ShiftLatin, Shift{Code: ShiftLatin, Encoding: LatinMorse}},
+		{"collision", ShiftRussian, Shift{Code: "...", Encoding: LatinMorse}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewConverter(DefaultMorse, WithDecoding(ЪЬ, 'Ь'), WithShifts(tt.baseShift, tt.shift))
+
+			var shiftErr ErrInvalidShift
+			require.ErrorAs(t, err, &shiftErr)
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package morse
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const streamChunkSize = 32 << 10
+
+// Encoder converts text written to it into Morse code on the fly.
+// Close must be called to flush a trailing incomplete rune and separator;
+// it does not close the underlying writer.
+type Encoder struct {
+	c       Converter
+	w       io.Writer
+	pending []byte
+	sep     bool
+	space   bool
+	cur     int
+	prosign []rune
+	inSign  bool
+	signPos Position
+	pos     Position
+	report  ConversionReport
+	err     error
+}
+
+// NewEncoder returns an encoder writing to w. One made from a Converter not
+// created by NewConverter fails with ErrNilEncodingMap.
+func (c Converter) NewEncoder(w io.Writer) *Encoder {
+	e := &Encoder{c: c, w: w}
+	if len(c.alphabets) == 0 {
+		e.err = ErrNilEncodingMap
+	}
+
+	return e
+}
+
+func (e *Encoder) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	data := p
+	if len(e.pending) != 0 {
+		data = append(e.pending, p...)
+		e.pending = nil
+	}
+
+	out := make([]byte, 0, int(float64(len(data))*averageSize))
+	for len(data) > 0 {
+		if !utf8.FullRune(data) {
+			break
+		}
+		r, size := utf8.DecodeRune(data)
+		out = e.encodeRune(out, r)
+		e.pos.advance(r, size)
+		data = data[size:]
+	}
+	e.pending = append(e.pending, data...)
+
+	if err := e.write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+
+	var out []byte
+	for len(e.pending) > 0 {
+		r, size := utf8.DecodeRune(e.pending)
+		out = e.encodeRune(out, r)
+		e.pos.advance(r, size)
+		e.pending = e.pending[size:]
+	}
+
+	if e.inSign {
+		out = e.flushProsign(out)
+	}
+
+	if e.c.trailingSeparator && e.sep {
+		out = append(out, e.c.charSeparator...)
+	}
+
+	return e.write(out)
+}
+
+// Report returns the runes that had no encoding so far.
+func (e *Encoder) Report() ConversionReport {
+	return e.report
+}
+
+// encodeRune collects "<NAME>" prosigns and passes other runes to appendRune.
+func (e *Encoder) encodeRune(out []byte, r rune) []byte {
+	if e.c.prosigns == nil {
+		return e.appendRune(out, r, e.pos)
+	}
+
+	if !e.inSign {
+		if r == prosignOpen {
+			e.inSign = true
+			e.signPos = e.pos
+			return out
+		}
+		return e.appendRune(out, r, e.pos)
+	}
+
+	switch {
+	case r == prosignClose:
+		if code, ok := e.c.prosignCode(string(e.prosign)); ok {
+			e.inSign = false
+			e.prosign = e.prosign[:0]
+			return e.appendCode(out, code)
+		}
+		out = e.flushProsign(out)
+	case r == prosignOpen:
+		out = e.flushProsign(out)
+		e.inSign = true
+		e.signPos = e.pos
+		return out
+	case len(e.prosign) >= e.c.maxProsign || unicode.IsSpace(r):
+		out = e.flushProsign(out)
+	default:
+		e.prosign = append(e.prosign, r)
+		return out
+	}
+
+	return e.appendRune(out, r, e.pos)
+}
+
+// flushProsign encodes a collected "<NAME" that turned out not to be a
+// prosign rune by rune.
+func (e *Encoder) flushProsign(out []byte) []byte {
+	e.inSign = false
+
+	pos := e.signPos
+	out = e.appendRune(out, prosignOpen, pos)
+	pos.advance(prosignOpen, 1)
+
+	for _, r := range e.prosign {
+		out = e.appendRune(out, r, pos)
+		pos.advance(r, utf8.RuneLen(r))
+	}
+	e.prosign = e.prosign[:0]
+
+	return out
+}
+
+func (e *Encoder) appendRune(out []byte, r rune, pos Position) []byte {
+	if e.c.convertToUpper {
+		r = unicode.ToUpper(r)
+	}
+
+	code, ok := e.c.alphabets[e.cur].runeToMorse[r]
+	if !ok {
+		if i := e.c.alphabetOf(r); i >= 0 {
+			e.cur = i
+			out = e.appendCode(out, e.c.alphabets[i].shift)
+			return e.appendCode(out, e.c.alphabets[i].runeToMorse[r])
+		}
+
+		// Whitespace separates words; runs of it make a single separator,
+		// and there is none before the first or after the last word.
+		if unicode.IsSpace(r) {
+			e.space = e.sep
+			return out
+		}
+
+		e.report.add(pos, string(r))
+		code = e.c.Handling(ErrNoEncoding{string(r)})
+		if code == "" {
+			return out
+		}
+	}
+
+	return e.appendCode(out, code)
+}
+
+func (e *Encoder) appendCode(out []byte, code string) []byte {
+	switch {
+	case e.space:
+		out = append(out, e.c.wordSeparator...)
+	case e.sep:
+		out = append(out, e.c.charSeparator...)
+	}
+	e.sep, e.space = true, false
+
+	return e.c.notation.appendCode(out, code)
+}
+
+func (e *Encoder) write(out []byte) error {
+	if len(out) == 0 {
+		return nil
+	}
+
+	if _, err := e.w.Write(out); err != nil {
+		e.err = err
+	}
+
+	return e.err
+}
+
+// Decoder reads Morse code from the underlying reader and returns decoded
+// text. Separators split across reads are handled transparently.
+type Decoder struct {
+	c        Converter
+	r        io.Reader
+	wordSeps []string
+	maxSep   int
+	charSep  []byte
+	in       []byte
+	out      []byte
+	buf      []byte
+	cur      int
+	started  bool
+	space    bool
+	pos      Position
+	report   ConversionReport
+	err      error
+}
+
+// NewDecoder returns a decoder reading from r. One made from a Converter
+// not created by NewConverter fails with ErrNilEncodingMap.
+func (c Converter) NewDecoder(r io.Reader) *Decoder {
+	d := &Decoder{
+		c:        c,
+		r:        r,
+		wordSeps: c.wordSeparators(),
+		charSep:  []byte(c.charSeparator),
+	}
+	for _, sep := range d.wordSeps {
+		d.maxSep = max(d.maxSep, len(sep))
+	}
+	if len(c.alphabets) == 0 {
+		d.err = ErrNilEncodingMap
+	}
+
+	return d
+}
+
+func (d *Decoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+
+		if d.buf == nil {
+			d.buf = make([]byte, streamChunkSize)
+		}
+
+		n, err := d.r.Read(d.buf)
+		d.in = append(d.in, d.buf[:n]...)
+
+		switch {
+		case err == io.EOF:
+			d.decode(true)
+			d.err = io.EOF
+		case err != nil:
+			d.err = err
+		default:
+			d.decode(false)
+		}
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	if len(d.out) == 0 {
+		d.out = nil
+	}
+
+	return n, nil
+}
+
+// Report returns the codes that had no decoding so far.
+func (d *Decoder) Report() ConversionReport {
+	return d.report
+}
+
+func (d *Decoder) decode(final bool) {
+	for {
+		i, n := indexSeparator(d.in, d.wordSeps)
+		// A longer separator may still begin at i.
+		if i < 0 || !final && i+d.maxSep > len(d.in) {
+			break
+		}
+
+		d.decodeChars(d.in[:i], true)
+		d.wordBreak()
+		d.pos.advanceBytes(d.in[i : i+n])
+		d.in = d.in[i+n:]
+	}
+
+	if final {
+		d.decodeChars(d.in, true)
+		if d.c.trailingSeparator && d.started {
+			d.out = append(d.out, ' ')
+		}
+		d.in = nil
+
+		return
+	}
+
+	// A word separator may still begin within the last maxSep-1 bytes.
+	safe := len(d.in) - d.maxSep + 1
+	if safe <= 0 {
+		return
+	}
+
+	n := d.decodeChars(d.in[:safe], false)
+	d.in = append(d.in[:0], d.in[n:]...)
+}
+
+// decodeChars decodes the characters of a single word and returns the number
+// of bytes consumed. Unless the word is complete, the last token is kept.
+func (d *Decoder) decodeChars(word []byte, complete bool) int {
+	consumed := 0
+
+	if len(d.charSep) == 0 {
+		for consumed < len(word) && (complete || utf8.FullRune(word[consumed:])) {
+			r, size := utf8.DecodeRune(word[consumed:])
+			d.decodeToken(word[consumed : consumed+size])
+			d.pos.advance(r, size)
+			consumed += size
+		}
+
+		return consumed
+	}
+
+	for {
+		i := bytes.Index(word[consumed:], d.charSep)
+		if i < 0 {
+			break
+		}
+
+		token := word[consumed : consumed+i]
+		d.decodeToken(token)
+		d.pos.advanceBytes(token)
+		d.pos.advanceBytes(d.charSep)
+		consumed += i + len(d.charSep)
+	}
+
+	if complete {
+		d.decodeToken(word[consumed:])
+		d.pos.advanceBytes(word[consumed:])
+		consumed = len(word)
+	}
+
+	return consumed
+}
+
+func (d *Decoder) decodeToken(token []byte) {
+	if len(token) == 0 {
+		return
+	}
+
+	code := token
+	if d.c.notation.elements != nil {
+		if parsed, ok := d.c.notation.Parse(string(token)); ok {
+			code = []byte(parsed)
+		}
+	}
+
+	if i, ok := d.c.shifts[string(code)]; ok {
+		d.cur = i
+		return
+	}
+
+	if r, ok := d.c.alphabets[d.cur].morseToRune[string(code)]; ok {
+		d.startChar()
+		d.out = utf8.AppendRune(d.out, r)
+		return
+	}
+
+	if name, ok := d.c.prosignNames[string(code)]; ok {
+		d.startChar()
+		d.out = append(d.out, prosignOpen)
+		d.out = append(d.out, name...)
+		d.out = append(d.out, prosignClose)
+		return
+	}
+
+	if d.c.correction.enabled() {
+		if text, ok := d.c.correct(string(code), d.c.alphabets[d.cur]); ok {
+			d.startChar()
+			d.out = append(d.out, text...)
+			d.report.correct(d.pos, string(token), text)
+			return
+		}
+	}
+
+	if IsWordMark(string(token)) {
+		d.wordBreak()
+		return
+	}
+
+	d.report.add(d.pos, string(token))
+	hand := d.c.Handling(ErrNoEncoding{string(token)})
+	if hand != "" {
+		d.startChar()
+		d.out = append(d.out, hand...)
+		d.out = append(d.out, d.charSep...)
+	}
+}
+
+// wordBreak separates words in the output. Runs of word separators make a
+// single space, and there is none before the first word.
+func (d *Decoder) wordBreak() {
+	d.space = d.started
+}
+
+// startChar puts a pending word break before the next output.
+func (d *Decoder) startChar() {
+	if d.space {
+		d.out = append(d.out, ' ')
+		d.space = false
+	}
+	d.started = true
+}
+
+// decodeWordSeparator is the word separator of the notation:
+// a space between two character separators.
+func (c Converter) decodeWordSeparator() string {
+	return c.charSeparator + c.notation.wordSpace() + c.charSeparator
+}
+
+// IsWordMark reports whether token separates words by convention, as in
+// ".- / -..." or ".- | -...".
+func IsWordMark(token string) bool {
+	return token == "/" || token == "|"
+}
+
+// wordSeparators are the separators recognized between words in Morse
+// input: the notation's, the configured one and line breaks. Those that
+// are part of the character separator are left out.
+func (c Converter) wordSeparators() []string {
+	var seps []string
+	for _, sep := range []string{c.decodeWordSeparator(), c.wordSeparator, "\r\n", "\n"} {
+		if sep != "" && !strings.Contains(c.charSeparator, sep) && !slices.Contains(seps, sep) {
+			seps = append(seps, sep)
+		}
+	}
+
+	return seps
+}
+
+// indexSeparator returns the index and the length of the first of seps in
+// s, the longest one if several begin there, or -1.
+func indexSeparator[T string | []byte](s T, seps []string) (int, int) {
+	for i := range len(s) {
+		n := 0
+		for _, sep := range seps {
+			if len(sep) > n && len(s)-i >= len(sep) && string(s[i:i+len(sep)]) == sep {
+				n = len(sep)
+			}
+		}
+		if n != 0 {
+			return i, n
+		}
+	}
+
+	return -1, 0
+}
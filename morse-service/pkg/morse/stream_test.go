@@ -0,0 +1,79 @@
+package morse
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoderMatchesToMorse(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Converter
+		in   string
+	}{
+		{"default", DefaultConverter, "Привет, мир!"},
+		{"trailing", MustNewConverter(DefaultMorse, WithDecoding(ЪЬ, 'Ь'), WithTrailingSeparator(true)), "ПРИВЕТ"},
+		{"pipe", MustNewConverter(DefaultMorse, WithDecoding(ЪЬ, 'Ь'), WithCharSeparator("|")), "СОС 123"},
+		{"empty", DefaultConverter, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := tt.c.NewEncoder(&buf)
+			// Feed the input byte by byte so that every multi-byte rune is split.
+			for _, b := range []byte(tt.in) {
+				_, err := enc.Write([]byte{b})
+				require.NoError(t, err)
+			}
+			require.NoError(t, enc.Close())
+
+			assert.Equal(t, tt.c.ToMorse(tt.in), buf.String())
+		})
+	}
+}
+
+func TestDecoderSeparatorsAcrossReads(t *testing.T) {
+	c := MustNewConverter(DefaultMorse, WithDecoding(ЪЬ, 'Ь'), WithCharSeparator("||"), WithTrailingSeparator(false))
+	in := ".--.||.-.||..|| ||--||..||.-."
+
+	got, err := io.ReadAll(c.NewDecoder(iotest.OneByteReader(strings.NewReader(in))))
+	require.NoError(t, err)
+	assert.Equal(t, "ПРИ МИР", string(got))
+}
+
+func TestDecoderMatchesToText(t *testing.T) {
+	in := strings.Repeat(".--. .-. .. .-- . -   -- .. .-.   ", 5000)
+
+	got, err := io.ReadAll(DefaultConverter.NewDecoder(strings.NewReader(in)))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultConverter.ToText(in), string(got))
+	assert.True(t, strings.HasPrefix(string(got), "ПРИВЕТ МИР ПРИВЕТ"))
+}
+
+func TestDecoderReadError(t *testing.T) {
+	_, err := io.ReadAll(DefaultConverter.NewDecoder(iotest.ErrReader(io.ErrUnexpectedEOF)))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestZeroConverter(t *testing.T) {
+	var c Converter
+
+	enc := c.NewEncoder(io.Discard)
+	_, err := enc.Write([]byte("СОС"))
+	require.ErrorIs(t, err, ErrNilEncodingMap)
+	require.ErrorIs(t, enc.Close(), ErrNilEncodingMap)
+
+	_, err = io.ReadAll(c.NewDecoder(strings.NewReader("... --- ...")))
+	require.ErrorIs(t, err, ErrNilEncodingMap)
+
+	assert.NotPanics(t, func() {
+		c.ToMorse("СОС")
+		c.ToText("... --- ...")
+	})
+}
@@ -0,0 +1,151 @@
+package morse
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"time"
+)
+
+// parisUnits is the length of the word "PARIS " in dot units, the standard
+// word for measuring Morse speed.
+const parisUnits = 50
+
+var ErrInvalidTiming = errors.New("invalid timing")
+
+// Element is a single key-down (On) or key-up period of a Morse signal.
+type Element struct {
+	On  bool
+	Dur time.Duration
+}
+
+// Timing describes the speed of a Morse signal. Characters are sent at the
+// character speed; with Farnsworth spacing the gaps between characters and
+// words are stretched so that the overall speed drops to the effective one.
+type Timing struct {
+	unit    time.Duration
+	charGap time.Duration
+	wordGap time.Duration
+}
+
+// NewTiming returns a timing for wpm words per minute. A farnsworth speed of
+// zero disables Farnsworth spacing; otherwise it must not exceed wpm.
+func NewTiming(wpm, farnsworth float64) (Timing, error) {
+	if wpm <= 0 {
+		return Timing{}, fmt.Errorf("%w: speed %v wpm", ErrInvalidTiming, wpm)
+	}
+	if farnsworth < 0 || farnsworth > wpm {
+		return Timing{}, fmt.Errorf("%w: farnsworth speed %v wpm", ErrInvalidTiming, farnsworth)
+	}
+
+	t := Timing{unit: time.Duration(float64(time.Minute) / parisUnits / wpm)}
+	t.charGap = 3 * t.unit
+	t.wordGap = 7 * t.unit
+
+	if farnsworth != 0 && farnsworth != wpm {
+		// ARRL formula: the 19 gap units of "PARIS " share the time left
+		// after sending its 31 character units at full speed.
+		total := float64(time.Minute) / farnsworth
+		delay := (total - 31*float64(t.unit)) / 19
+		t.charGap = time.Duration(3 * delay)
+		t.wordGap = time.Duration(7 * delay)
+	}
+
+	return t, nil
+}
+
+func (t Timing) Unit() time.Duration {
+	return t.unit
+}
+
+// Elements renders Morse code produced by c as a list of key-down and
+// key-up periods.
+func (c Converter) Elements(morse string, t Timing) []Element {
+	return slices.Collect(c.Signal(morse, t))
+}
+
+// Signal is like Elements, but yields the periods one by one.
+func (c Converter) Signal(morse string, t Timing) iter.Seq[Element] {
+	return func(yield func(Element) bool) {
+		started := false
+		gap := time.Duration(0)
+
+		for word := range c.words(morse) {
+			if started {
+				gap = t.wordGap
+			}
+
+			for token := range c.chars(word) {
+				if IsWordMark(token) {
+					if started {
+						gap = t.wordGap
+					}
+					continue
+				}
+
+				marks := false
+
+				code, _ := c.notation.Parse(token)
+				for _, r := range code {
+					var on time.Duration
+					switch r {
+					case '.':
+						on = t.unit
+					case '-':
+						on = 3 * t.unit
+					default:
+						continue
+					}
+
+					if started && !yield(Element{On: false, Dur: gap}) {
+						return
+					}
+					if !yield(Element{On: true, Dur: on}) {
+						return
+					}
+
+					started, marks = true, true
+					gap = t.unit
+				}
+
+				if marks {
+					gap = t.charGap
+				}
+			}
+		}
+	}
+}
+
+// Duration returns the total length of the elements.
+func Duration(elements []Element) time.Duration {
+	var total time.Duration
+	for _, e := range elements {
+		total += e.Dur
+	}
+
+	return total
+}
+
+func (c Converter) words(morse string) iter.Seq[string] {
+	seps := c.wordSeparators()
+
+	return func(yield func(string) bool) {
+		for {
+			i, n := indexSeparator(morse, seps)
+			if i < 0 {
+				yield(morse)
+				return
+			}
+			if !yield(morse[:i]) {
+				return
+			}
+			morse = morse[i+n:]
+		}
+	}
+}
+
+func (c Converter) chars(word string) iter.Seq[string] {
+	return strings.SplitSeq(word, c.charSeparator)
+}
@@ -0,0 +1,70 @@
+package morse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTiming(t *testing.T) {
+	tm, err := NewTiming(20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 60*time.Millisecond, tm.Unit())
+
+	for _, tt := range [][2]float64{{0, 0}, {-5, 0}, {20, 25}, {20, -1}} {
+		_, err := NewTiming(tt[0], tt[1])
+		require.ErrorIs(t, err, ErrInvalidTiming, tt)
+	}
+}
+
+func TestElements(t *testing.T) {
+	tm, err := NewTiming(20, 0)
+	require.NoError(t, err)
+
+	u := tm.Unit()
+	got := DefaultConverter.Elements(".- -   .", tm)
+	assert.Equal(t, []Element{
+		{On: true, Dur: u},
+		{On: false, Dur: u},
+		{On: true, Dur: 3 * u},
+		{On: false, Dur: 3 * u},
+		{On: true, Dur: 3 * u},
+		{On: false, Dur: 7 * u},
+		{On: true, Dur: u},
+	}, got)
+}
+
+func TestElementsParis(t *testing.T) {
+	paris := DefaultConverter.ToMorse("ПАРИС") + "   "
+
+	for _, tt := range []struct{ wpm, farnsworth float64 }{{20, 0}, {20, 20}, {20, 5}, {13, 7.5}} {
+		tm, err := NewTiming(tt.wpm, tt.farnsworth)
+		require.NoError(t, err)
+
+		speed := tt.farnsworth
+		if speed == 0 {
+			speed = tt.wpm
+		}
+
+		// Repeating "PARIS " for a minute gives exactly the effective speed.
+		elements := DefaultConverter.Elements(paris+paris, tm)
+		perWord := (Duration(elements) + tm.wordGap) / 2
+		assert.InDelta(t, float64(time.Minute)/speed, float64(perWord), float64(time.Microsecond), tt)
+	}
+}
+
+func TestSignalStops(t *testing.T) {
+	tm, err := NewTiming(20, 0)
+	require.NoError(t, err)
+
+	n := 0
+	for range DefaultConverter.Signal("... --- ...", tm) {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, n)
+}